@@ -0,0 +1,105 @@
+package apperror
+
+import "errors"
+
+// Kind classifies an error so that the HTTP layer can pick a status code
+// without knowing about the domain.
+type Kind int
+
+const (
+	Internal Kind = iota
+	BadRequest
+	NotFound
+	Validation
+	Conflict
+	PreconditionFailed
+	UnsupportedMediaType
+	Unauthorized
+	Forbidden
+	TooManyRequests
+	Unavailable
+)
+
+// Error is a domain error with a stable, machine readable code that clients
+// can branch on.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors sharing the same code, so that a wrapped sentinel is
+// still recognised by errors.Is.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Wrap returns a copy of e carrying err as its cause.
+func (e *Error) Wrap(err error) *Error {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func NewBadRequest(code, message string) *Error {
+	return New(BadRequest, code, message)
+}
+
+func NewNotFound(code, message string) *Error {
+	return New(NotFound, code, message)
+}
+
+func NewValidation(code, message string) *Error {
+	return New(Validation, code, message)
+}
+
+func NewConflict(code, message string) *Error {
+	return New(Conflict, code, message)
+}
+
+func NewPreconditionFailed(code, message string) *Error {
+	return New(PreconditionFailed, code, message)
+}
+
+func NewUnauthorized(code, message string) *Error {
+	return New(Unauthorized, code, message)
+}
+
+func NewForbidden(code, message string) *Error {
+	return New(Forbidden, code, message)
+}
+
+func NewTooManyRequests(code, message string) *Error {
+	return New(TooManyRequests, code, message)
+}
+
+func NewUnavailable(code, message string) *Error {
+	return New(Unavailable, code, message)
+}
+
+// KindOf returns the kind of the first *Error found in err's chain, Internal
+// when there is none.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return Internal
+}
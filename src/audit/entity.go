@@ -0,0 +1,45 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Action is the kind of change an entry records.
+type Action string
+
+const (
+	Create  Action = "create"
+	Update  Action = "update"
+	Delete  Action = "delete"
+	Restore Action = "restore"
+)
+
+// Entry records a change of an entity: who made it, from which request, and
+// the entity before and after it. Entries are never modified nor deleted.
+type Entry struct {
+	ID        int               `json:"id"`
+	TenantID  string            `json:"-"`
+	Actor     string            `json:"actor"`
+	UserID    *int              `json:"user_id"`
+	KeyID     *int              `json:"key_id"`
+	Action    Action            `json:"action"`
+	Entity    string            `json:"entity"`
+	EntityID  int               `json:"entity_id"`
+	Before    json.RawMessage   `json:"before" gorm:"serializer:json"`
+	After     json.RawMessage   `json:"after" gorm:"serializer:json"`
+	Changes   map[string]Change `json:"changes" gorm:"serializer:json"`
+	RequestID string            `json:"request_id"`
+	IP        string            `json:"ip"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+func (Entry) TableName() string {
+	return "audit_entries"
+}
+
+// Change is the value of a field before and after a change.
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
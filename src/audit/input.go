@@ -0,0 +1,18 @@
+package audit
+
+import "time"
+
+// ListOptions selects the entries to list, newest first. Empty filters are
+// ignored and a zero PerPage lists all of them.
+type ListOptions struct {
+	Entity    string     `form:"entity"`
+	EntityID  int        `form:"entity_id"`
+	Action    Action     `form:"action"`
+	UserID    int        `form:"user_id"`
+	KeyID     int        `form:"key_id"`
+	RequestID string     `form:"request_id"`
+	From      *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To        *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Page      int        `form:"-"`
+	PerPage   int        `form:"-"`
+}
@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"context"
+	"go/src/tenant"
+	"sync"
+	"time"
+)
+
+// memoryRepository keeps the entries in memory, for tests and demos.
+type memoryRepository struct {
+	mu      sync.RWMutex
+	entries []Entry
+}
+
+func NewMemoryRepository() *memoryRepository {
+	return &memoryRepository{}
+}
+
+func (r *memoryRepository) Create(ctx context.Context, entry Entry) (Entry, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return entry, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry.ID = len(r.entries) + 1
+	entry.TenantID = tenantID
+	entry.CreatedAt = time.Now()
+	r.entries = append(r.entries, entry)
+
+	return entry, nil
+}
+
+func (r *memoryRepository) GetAll(ctx context.Context, options ListOptions) ([]Entry, int64, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := []Entry{}
+	for i := len(r.entries) - 1; i >= 0; i-- {
+		entry := r.entries[i]
+		if entry.TenantID == tenantID && options.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	total := int64(len(entries))
+	if options.PerPage > 0 {
+		start := (options.Page - 1) * options.PerPage
+		if start > len(entries) {
+			start = len(entries)
+		}
+		end := start + options.PerPage
+		if end > len(entries) {
+			end = len(entries)
+		}
+		entries = entries[start:end]
+	}
+
+	return entries, total, nil
+}
+
+// matches applies the filters of options to entry, like the GORM repository.
+func (options ListOptions) matches(entry Entry) bool {
+	switch {
+	case options.Entity != "" && entry.Entity != options.Entity,
+		options.EntityID != 0 && entry.EntityID != options.EntityID,
+		options.Action != "" && entry.Action != options.Action,
+		options.UserID != 0 && (entry.UserID == nil || *entry.UserID != options.UserID),
+		options.KeyID != 0 && (entry.KeyID == nil || *entry.KeyID != options.KeyID),
+		options.RequestID != "" && entry.RequestID != options.RequestID,
+		options.From != nil && entry.CreatedAt.Before(*options.From),
+		options.To != nil && !entry.CreatedAt.Before(*options.To):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package audit
+
+import (
+	"context"
+	"go/src/database"
+	"go/src/tenant"
+
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	Create(ctx context.Context, entry Entry) (Entry, error)
+	GetAll(ctx context.Context, options ListOptions) ([]Entry, int64, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *repository {
+	return &repository{db}
+}
+
+func (r *repository) Create(ctx context.Context, entry Entry) (Entry, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return entry, err
+	}
+	entry.TenantID = tenantID
+
+	err = database.Conn(ctx, r.db).Create(&entry).Error
+	if err != nil {
+		return entry, err
+	}
+
+	return entry, nil
+}
+
+func (r *repository) GetAll(ctx context.Context, options ListOptions) ([]Entry, int64, error) {
+	var entries []Entry
+	var total int64
+
+	db := database.Conn(ctx, r.db).Scopes(tenant.Scope)
+	if options.Entity != "" {
+		db = db.Where("entity = ?", options.Entity)
+	}
+	if options.EntityID != 0 {
+		db = db.Where("entity_id = ?", options.EntityID)
+	}
+	if options.Action != "" {
+		db = db.Where("action = ?", options.Action)
+	}
+	if options.UserID != 0 {
+		db = db.Where("user_id = ?", options.UserID)
+	}
+	if options.KeyID != 0 {
+		db = db.Where("key_id = ?", options.KeyID)
+	}
+	if options.RequestID != "" {
+		db = db.Where("request_id = ?", options.RequestID)
+	}
+	if options.From != nil {
+		db = db.Where("created_at >= ?", *options.From)
+	}
+	if options.To != nil {
+		db = db.Where("created_at < ?", *options.To)
+	}
+	db = db.Session(&gorm.Session{})
+
+	err := db.Model(&Entry{}).Count(&total).Error
+	if err != nil {
+		return entries, total, err
+	}
+
+	if options.PerPage > 0 {
+		db = db.Limit(options.PerPage).Offset((options.Page - 1) * options.PerPage)
+	}
+
+	err = db.Order("id DESC").Find(&entries).Error
+	if err != nil {
+		return entries, total, err
+	}
+
+	return entries, total, nil
+}
@@ -0,0 +1,132 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/src/auth"
+	"go/src/request"
+	"reflect"
+)
+
+// Recorder records the changes of entities. before is nil for a creation,
+// after for a deletion. The entry is written in the transaction of ctx, so
+// that a change is not made without it.
+type Recorder interface {
+	Record(ctx context.Context, action Action, entity string, id int, before interface{}, after interface{}) error
+}
+
+type Service interface {
+	Recorder
+	GetAll(ctx context.Context, options ListOptions) ([]Entry, int64, error)
+}
+
+type service struct {
+	repository Repository
+}
+
+func NewService(r Repository) *service {
+	return &service{r}
+}
+
+// Record stores the change with the subject and the request of ctx.
+func (s *service) Record(ctx context.Context, action Action, entity string, id int, before interface{}, after interface{}) error {
+	entry, err := newEntry(ctx, action, entity, id, before, after)
+	if err != nil {
+		return fmt.Errorf("audit entry: %w", err)
+	}
+
+	_, err = s.repository.Create(ctx, entry)
+	if err != nil {
+		return fmt.Errorf("audit entry: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) GetAll(ctx context.Context, options ListOptions) ([]Entry, int64, error) {
+	entries, total, err := s.repository.GetAll(ctx, options)
+	if err != nil {
+		return entries, total, err
+	}
+
+	return entries, total, nil
+}
+
+func newEntry(ctx context.Context, action Action, entity string, id int, before interface{}, after interface{}) (Entry, error) {
+	entry := Entry{
+		Actor:    "system",
+		Action:   action,
+		Entity:   entity,
+		EntityID: id,
+	}
+
+	if subject, ok := auth.SubjectFrom(ctx); ok {
+		entry.UserID = &subject.UserID
+		entry.Actor = subject.Username
+		if subject.KeyID != 0 {
+			entry.KeyID = &subject.KeyID
+			entry.Actor = fmt.Sprintf("key:%d", subject.KeyID)
+		}
+	}
+	if info, ok := request.FromContext(ctx); ok {
+		entry.RequestID = info.ID
+		entry.IP = info.IP
+	}
+
+	var err error
+	entry.Before, err = snapshot(before)
+	if err != nil {
+		return entry, err
+	}
+	entry.After, err = snapshot(after)
+	if err != nil {
+		return entry, err
+	}
+	entry.Changes, err = diff(entry.Before, entry.After)
+	if err != nil {
+		return entry, err
+	}
+
+	return entry, nil
+}
+
+// snapshot is the JSON of value, as the API shows it, nil for no value.
+func snapshot(value interface{}) (json.RawMessage, error) {
+	if value == nil {
+		return nil, nil
+	}
+	return json.Marshal(value)
+}
+
+// diff returns the top level fields whose value differs between two
+// snapshots.
+func diff(before json.RawMessage, after json.RawMessage) (map[string]Change, error) {
+	var previous, current map[string]interface{}
+	if before != nil {
+		err := json.Unmarshal(before, &previous)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if after != nil {
+		err := json.Unmarshal(after, &current)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	//a field missing on one side is null there
+	changes := make(map[string]Change)
+	for field, value := range previous {
+		if !reflect.DeepEqual(value, current[field]) {
+			changes[field] = Change{Before: value, After: current[field]}
+		}
+	}
+	for field, value := range current {
+		if _, ok := previous[field]; !ok && value != nil {
+			changes[field] = Change{After: value}
+		}
+	}
+	return changes, nil
+}
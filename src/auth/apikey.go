@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+const (
+	// keyPrefix starts every API key, so that they are told apart from
+	// access tokens and found by secret scanners.
+	keyPrefix = "gk_"
+
+	// keyTouchInterval limits the writes of LastUsedAt to one per key and
+	// interval.
+	keyTouchInterval = time.Minute
+)
+
+// IsAPIKey reports whether credential looks like an API key rather than an
+// access token.
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, keyPrefix)
+}
+
+// generateKey returns a new key, gk_<prefix>_<secret>, with its prefix.
+func generateKey() (key string, prefix string, err error) {
+	id := make([]byte, 6)
+	_, err = rand.Read(id)
+	if err != nil {
+		return "", "", err
+	}
+
+	secret := make([]byte, 32)
+	_, err = rand.Read(secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	prefix = keyPrefix + hex.EncodeToString(id)
+	return prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), prefix, nil
+}
+
+// splitKey returns the prefix of a key, false when it is malformed.
+func splitKey(key string) (string, bool) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(key, keyPrefix), "_")
+	if !IsAPIKey(key) || !ok || id == "" || secret == "" {
+		return "", false
+	}
+	return keyPrefix + id, true
+}
+
+// hashKey is a plain SHA-256: keys are random, unlike passwords they need no
+// slow hash.
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func keyMatches(key string, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashKey(key)), []byte(hash)) == 1
+}
@@ -0,0 +1,16 @@
+package auth
+
+import "context"
+
+type subjectKey struct{}
+
+// WithSubject returns a copy of ctx carrying the authenticated subject.
+func WithSubject(ctx context.Context, subject Subject) context.Context {
+	return context.WithValue(ctx, subjectKey{}, subject)
+}
+
+// SubjectFrom returns the subject authenticated for ctx, if any.
+func SubjectFrom(ctx context.Context) (Subject, bool) {
+	subject, ok := ctx.Value(subjectKey{}).(Subject)
+	return subject, ok
+}
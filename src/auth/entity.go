@@ -0,0 +1,79 @@
+package auth
+
+import "time"
+
+type User struct {
+	ID           int       `json:"id"`
+	Username     string    `json:"username"`
+	TenantID     string    `json:"tenant_id"`
+	PasswordHash string    `json:"-"`
+	Roles        []string  `json:"roles" gorm:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// AdminRole is created by the migrations with every permission, it cannot be
+// modified nor deleted so that someone can always manage the roles.
+const AdminRole = "admin"
+
+type Role struct {
+	ID          int          `json:"id"`
+	Name        string       `json:"name"`
+	Permissions []Permission `json:"permissions" gorm:"serializer:json"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// userRole assigns a role to a user.
+type userRole struct {
+	UserID int
+	RoleID int
+}
+
+func (userRole) TableName() string {
+	return "user_roles"
+}
+
+// Tokens are issued on login and refresh. The access token authenticates
+// the requests, the refresh token gets a new pair once it expires.
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// APIKey authenticates a machine client with its own permissions. Only the
+// hash of the key is stored, Prefix identifies it in listings and logs.
+type APIKey struct {
+	ID          int          `json:"id"`
+	UserID      int          `json:"user_id"`
+	TenantID    string       `json:"tenant_id"`
+	Name        string       `json:"name"`
+	Prefix      string       `json:"prefix"`
+	Hash        string       `json:"-"`
+	Permissions []Permission `json:"permissions" gorm:"serializer:json"`
+	ExpiresAt   *time.Time   `json:"expires_at"`
+	LastUsedAt  *time.Time   `json:"last_used_at"`
+	RevokedAt   *time.Time   `json:"revoked_at"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// NewAPIKey is returned once, when the key is created: Key is not stored and
+// cannot be shown again.
+type NewAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// Subject is the authenticated user of a request. Requests authenticated
+// with an API key have the key's ID, tenant and permissions, UserID is the
+// user who created it. An empty Tenant lets the subject pick one per request.
+type Subject struct {
+	UserID      int
+	Username    string
+	KeyID       int
+	Tenant      string
+	Permissions []Permission
+}
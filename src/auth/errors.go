@@ -0,0 +1,22 @@
+package auth
+
+import "go/src/apperror"
+
+var (
+	ErrInvalidCredentials = apperror.NewUnauthorized("invalid_credentials", "invalid username or password")
+	ErrInvalidToken       = apperror.NewUnauthorized("invalid_token", "token is missing, invalid or expired")
+	ErrUserNotFound       = apperror.NewNotFound("user_not_found", "user not found")
+	ErrUserExists         = apperror.NewConflict("user_exists", "username is already taken")
+	ErrWeakPassword       = apperror.NewValidation("weak_password", "password must be at least 8 characters long")
+	ErrForbidden          = apperror.NewForbidden("forbidden", "permission denied")
+	ErrRoleNotFound       = apperror.NewNotFound("role_not_found", "role not found")
+	ErrRoleExists         = apperror.NewConflict("role_exists", "role name is already taken")
+	ErrRoleInUse          = apperror.NewConflict("role_in_use", "role is assigned to users")
+	ErrRoleProtected      = apperror.NewConflict("role_protected", "the admin role cannot be modified")
+	ErrUnknownRole        = apperror.NewValidation("unknown_role", "role does not exist")
+	ErrUnknownPermission  = apperror.NewValidation("unknown_permission", "permission does not exist")
+	ErrKeyNotFound        = apperror.NewNotFound("key_not_found", "API key not found")
+	ErrInvalidExpiry      = apperror.NewValidation("invalid_expiry", "expires_at must be in the future")
+	ErrTenantForbidden    = apperror.NewForbidden("tenant_forbidden", "credential is bound to another tenant")
+	ErrRolesShared        = apperror.NewForbidden("roles_shared", "roles are shared by all tenants, credentials bound to a tenant cannot edit them")
+)
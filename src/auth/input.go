@@ -0,0 +1,36 @@
+package auth
+
+import "time"
+
+type InputLogin struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type InputRefresh struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// InputUser is not bound from requests, users are created from the command
+// line.
+type InputUser struct {
+	Username string
+	Password string
+	Roles    []string
+	TenantID string
+}
+
+type InputRole struct {
+	Name        string       `json:"name" binding:"required,max=191"`
+	Permissions []Permission `json:"permissions" binding:"required"`
+}
+
+type InputUserRoles struct {
+	Roles []string `json:"roles" binding:"required"`
+}
+
+type InputAPIKey struct {
+	Name        string       `json:"name" binding:"required,max=191"`
+	Permissions []Permission `json:"permissions" binding:"required"`
+	ExpiresAt   *time.Time   `json:"expires_at"`
+}
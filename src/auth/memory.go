@@ -0,0 +1,339 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultRoles are the roles created by the migrations.
+var defaultRoles = []Role{
+	{Name: AdminRole, Permissions: []Permission{AllPermissions}},
+	{Name: "cashier", Permissions: []Permission{ProductsRead, PaymentsRead, PaymentsWrite, StreamSubscribe}},
+	{Name: "editor", Permissions: []Permission{ProductsRead, ProductsWrite, ProductsDelete}},
+}
+
+// memoryRepository keeps the users and roles in memory, for tests and demos.
+type memoryRepository struct {
+	mu         sync.RWMutex
+	users      map[int]User
+	lastID     int
+	roles      map[int]Role
+	lastRoleID int
+	userRoles  map[int][]int
+	keys       map[int]APIKey
+	lastKeyID  int
+}
+
+func NewMemoryRepository() *memoryRepository {
+	r := &memoryRepository{
+		users:     make(map[int]User),
+		roles:     make(map[int]Role),
+		userRoles: make(map[int][]int),
+		keys:      make(map[int]APIKey),
+	}
+
+	now := time.Now()
+	for _, role := range defaultRoles {
+		r.lastRoleID++
+		role.ID = r.lastRoleID
+		role.CreatedAt = now
+		role.UpdatedAt = now
+		r.roles[role.ID] = role
+	}
+
+	return r
+}
+
+func (r *memoryRepository) Create(ctx context.Context, user User) (User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.users {
+		if existing.Username == user.Username {
+			return user, ErrUserExists
+		}
+	}
+
+	roleIDs, err := r.roleIDs(user.Roles)
+	if err != nil {
+		return user, err
+	}
+
+	now := time.Now()
+	r.lastID++
+	user.ID = r.lastID
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	r.users[user.ID] = user
+	r.userRoles[user.ID] = roleIDs
+
+	return r.withRoles(user), nil
+}
+
+func (r *memoryRepository) GetAll(ctx context.Context, tenantID string) ([]User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]User, 0, len(r.users))
+	for _, user := range r.users {
+		if tenantID == "" || user.TenantID == tenantID {
+			users = append(users, r.withRoles(user))
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+	return users, nil
+}
+
+func (r *memoryRepository) GetById(ctx context.Context, id int) (User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	return r.withRoles(user), nil
+}
+
+func (r *memoryRepository) GetByUsername(ctx context.Context, username string) (User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return User{}, ErrUserNotFound
+}
+
+func (r *memoryRepository) SetRoles(ctx context.Context, tenantID string, id int, roles []string) (User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || tenantID != "" && user.TenantID != tenantID {
+		return User{}, ErrUserNotFound
+	}
+
+	roleIDs, err := r.roleIDs(roles)
+	if err != nil {
+		return user, err
+	}
+	r.userRoles[id] = roleIDs
+
+	return r.withRoles(user), nil
+}
+
+func (r *memoryRepository) GetPermissions(ctx context.Context, id int) ([]Permission, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var permissions []Permission
+	for _, roleID := range r.userRoles[id] {
+		permissions = append(permissions, r.roles[roleID].Permissions...)
+	}
+	return permissions, nil
+}
+
+func (r *memoryRepository) GetRoles(ctx context.Context) ([]Role, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	roles := make([]Role, 0, len(r.roles))
+	for _, role := range r.roles {
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].ID < roles[j].ID
+	})
+	return roles, nil
+}
+
+func (r *memoryRepository) GetRoleById(ctx context.Context, id int) (Role, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	role, ok := r.roles[id]
+	if !ok {
+		return Role{}, ErrRoleNotFound
+	}
+	return role, nil
+}
+
+func (r *memoryRepository) CreateRole(ctx context.Context, role Role) (Role, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.nameTaken(role) {
+		return role, ErrRoleExists
+	}
+
+	now := time.Now()
+	r.lastRoleID++
+	role.ID = r.lastRoleID
+	role.CreatedAt = now
+	role.UpdatedAt = now
+	r.roles[role.ID] = role
+
+	return role, nil
+}
+
+func (r *memoryRepository) UpdateRole(ctx context.Context, role Role) (Role, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.roles[role.ID]
+	if !ok {
+		return role, ErrRoleNotFound
+	}
+	if r.nameTaken(role) {
+		return role, ErrRoleExists
+	}
+
+	existing.Name = role.Name
+	existing.Permissions = role.Permissions
+	existing.UpdatedAt = time.Now()
+	r.roles[role.ID] = existing
+
+	return existing, nil
+}
+
+func (r *memoryRepository) DeleteRole(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.roles[id]; !ok {
+		return ErrRoleNotFound
+	}
+	for _, roleIDs := range r.userRoles {
+		for _, roleID := range roleIDs {
+			if roleID == id {
+				return ErrRoleInUse
+			}
+		}
+	}
+
+	delete(r.roles, id)
+	return nil
+}
+
+func (r *memoryRepository) CreateKey(ctx context.Context, key APIKey) (APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.lastKeyID++
+	key.ID = r.lastKeyID
+	key.CreatedAt = now
+	key.UpdatedAt = now
+	r.keys[key.ID] = key
+
+	return key, nil
+}
+
+func (r *memoryRepository) GetKeys(ctx context.Context, tenantID string) ([]APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := []APIKey{}
+	for _, key := range r.keys {
+		if key.TenantID == tenantID {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+	return keys, nil
+}
+
+func (r *memoryRepository) GetKeyByPrefix(ctx context.Context, prefix string) (APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if key.Prefix == prefix {
+			return key, nil
+		}
+	}
+	return APIKey{}, ErrKeyNotFound
+}
+
+func (r *memoryRepository) RevokeKey(ctx context.Context, tenantID string, id int, at time.Time) (APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.keys[id]
+	if !ok || key.TenantID != tenantID {
+		return APIKey{}, ErrKeyNotFound
+	}
+
+	if key.RevokedAt == nil {
+		key.RevokedAt = &at
+		key.UpdatedAt = at
+		r.keys[id] = key
+	}
+	return key, nil
+}
+
+func (r *memoryRepository) TouchKey(ctx context.Context, id int, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.keys[id]
+	if ok {
+		key.LastUsedAt = &at
+		r.keys[id] = key
+	}
+	return nil
+}
+
+func (r *memoryRepository) nameTaken(role Role) bool {
+	for _, existing := range r.roles {
+		if existing.Name == role.Name && existing.ID != role.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// roleIDs resolves role names, ignoring duplicates.
+func (r *memoryRepository) roleIDs(names []string) ([]int, error) {
+	var ids []int
+	for _, name := range names {
+		id := 0
+		for _, role := range r.roles {
+			if role.Name == name {
+				id = role.ID
+			}
+		}
+		if id == 0 {
+			return nil, ErrUnknownRole.Wrap(fmt.Errorf("%s", name))
+		}
+
+		duplicate := false
+		for _, existing := range ids {
+			duplicate = duplicate || existing == id
+		}
+		if !duplicate {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// withRoles fills the role names of user, sorted like the database does.
+func (r *memoryRepository) withRoles(user User) User {
+	user.Roles = []string{}
+	for _, roleID := range r.userRoles[user.ID] {
+		user.Roles = append(user.Roles, r.roles[roleID].Name)
+	}
+	sort.Strings(user.Roles)
+	return user
+}
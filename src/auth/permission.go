@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"go/src/tenant"
+)
+
+// Permission allows an action on a resource. Roles grant permissions and
+// users get the permissions of all their roles.
+type Permission string
+
+const (
+	ProductsRead    Permission = "products:read"
+	ProductsWrite   Permission = "products:write"
+	ProductsDelete  Permission = "products:delete"
+	PaymentsRead    Permission = "payments:read"
+	PaymentsWrite   Permission = "payments:write"
+	PaymentsDelete  Permission = "payments:delete"
+	StreamSubscribe Permission = "stream:subscribe"
+	RolesManage     Permission = "roles:manage"
+	KeysManage      Permission = "keys:manage"
+	AuditRead       Permission = "audit:read"
+
+	// AllPermissions grants every permission, including the ones added later.
+	AllPermissions Permission = "*"
+)
+
+var permissions = []Permission{
+	ProductsRead,
+	ProductsWrite,
+	ProductsDelete,
+	PaymentsRead,
+	PaymentsWrite,
+	PaymentsDelete,
+	StreamSubscribe,
+	RolesManage,
+	KeysManage,
+	AuditRead,
+	AllPermissions,
+}
+
+func (p Permission) valid() bool {
+	for _, permission := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// MissingPermission is the cause of ErrForbidden, it names the permission
+// the subject lacks.
+type MissingPermission struct {
+	Permission Permission
+}
+
+func (m MissingPermission) Error() string {
+	return fmt.Sprintf("missing permission %s", m.Permission)
+}
+
+// Can reports whether the subject was granted permission.
+func (s Subject) Can(permission Permission) bool {
+	for _, granted := range s.Permissions {
+		if granted == permission || granted == AllPermissions {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize checks that the subject authenticated for ctx was granted
+// permission.
+func Authorize(ctx context.Context, permission Permission) error {
+	subject, ok := SubjectFrom(ctx)
+	if !ok {
+		return ErrInvalidToken
+	}
+	if !subject.Can(permission) {
+		return ErrForbidden.Wrap(MissingPermission{permission})
+	}
+	return nil
+}
+
+// ResolveTenant returns the tenant a request of the subject works on, given
+// the one it asks for: the tenant the subject is bound to or, for subjects
+// bound to none, the requested one, tenant.Default when none is.
+func (s Subject) ResolveTenant(requested string) (string, error) {
+	if requested != "" && !tenant.Valid(requested) {
+		return "", tenant.ErrInvalid
+	}
+
+	switch {
+	case s.Tenant != "" && requested != "" && requested != s.Tenant:
+		return "", ErrTenantForbidden
+	case s.Tenant != "":
+		return s.Tenant, nil
+	case requested != "":
+		return requested, nil
+	}
+	return tenant.Default, nil
+}
@@ -0,0 +1,415 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/src/database"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	Create(ctx context.Context, user User) (User, error)
+	GetAll(ctx context.Context, tenantID string) ([]User, error)
+	GetById(ctx context.Context, id int) (User, error)
+	GetByUsername(ctx context.Context, username string) (User, error)
+	SetRoles(ctx context.Context, tenantID string, id int, roles []string) (User, error)
+	GetPermissions(ctx context.Context, id int) ([]Permission, error)
+	GetRoles(ctx context.Context) ([]Role, error)
+	GetRoleById(ctx context.Context, id int) (Role, error)
+	CreateRole(ctx context.Context, role Role) (Role, error)
+	UpdateRole(ctx context.Context, role Role) (Role, error)
+	DeleteRole(ctx context.Context, id int) error
+	CreateKey(ctx context.Context, key APIKey) (APIKey, error)
+	GetKeys(ctx context.Context, tenantID string) ([]APIKey, error)
+	GetKeyByPrefix(ctx context.Context, prefix string) (APIKey, error)
+	RevokeKey(ctx context.Context, tenantID string, id int, at time.Time) (APIKey, error)
+	TouchKey(ctx context.Context, id int, at time.Time) error
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *repository {
+	return &repository{db}
+}
+
+func (r *repository) Create(ctx context.Context, user User) (User, error) {
+	err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Model(&User{}).Where("username = ?", user.Username).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrUserExists
+		}
+
+		err = tx.Create(&user).Error
+		if err != nil {
+			return err
+		}
+
+		user.Roles, err = assignRoles(tx, user.ID, user.Roles)
+		return err
+	})
+	if err != nil {
+		return user, err
+	}
+
+	return user, nil
+}
+
+// GetAll lists the users of tenantID, the ones of every tenant for an empty
+// tenantID.
+func (r *repository) GetAll(ctx context.Context, tenantID string) ([]User, error) {
+	var users []User
+	db := database.Conn(ctx, r.db)
+
+	err := db.Scopes(userTenant(tenantID)).Order("id").Find(&users).Error
+	if err != nil {
+		return users, err
+	}
+
+	err = loadRoles(db, users)
+	if err != nil {
+		return users, err
+	}
+
+	return users, nil
+}
+
+func (r *repository) GetById(ctx context.Context, id int) (User, error) {
+	var user User
+	db := database.Conn(ctx, r.db)
+
+	err := db.Where(&User{ID: id}).First(&user).Error
+	if err != nil {
+		return user, notFound(err)
+	}
+
+	users := []User{user}
+	err = loadRoles(db, users)
+	if err != nil {
+		return user, err
+	}
+
+	return users[0], nil
+}
+
+func (r *repository) GetByUsername(ctx context.Context, username string) (User, error) {
+	var user User
+
+	err := database.Conn(ctx, r.db).Where("username = ?", username).First(&user).Error
+	if err != nil {
+		return user, notFound(err)
+	}
+
+	return user, nil
+}
+
+// SetRoles replaces the roles of a user of tenantID, of any tenant for an
+// empty tenantID.
+func (r *repository) SetRoles(ctx context.Context, tenantID string, id int, roles []string) (User, error) {
+	var user User
+
+	err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		err := tx.Scopes(userTenant(tenantID)).Where(&User{ID: id}).First(&user).Error
+		if err != nil {
+			return notFound(err)
+		}
+
+		user.Roles, err = assignRoles(tx, id, roles)
+		return err
+	})
+	if err != nil {
+		return user, err
+	}
+
+	return user, nil
+}
+
+func (r *repository) GetPermissions(ctx context.Context, id int) ([]Permission, error) {
+	var roles []Role
+
+	err := database.Conn(ctx, r.db).Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", id).
+		Find(&roles).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var permissions []Permission
+	for _, role := range roles {
+		permissions = append(permissions, role.Permissions...)
+	}
+	return permissions, nil
+}
+
+func (r *repository) GetRoles(ctx context.Context) ([]Role, error) {
+	var roles []Role
+
+	err := database.Conn(ctx, r.db).Order("id").Find(&roles).Error
+	if err != nil {
+		return roles, err
+	}
+
+	return roles, nil
+}
+
+func (r *repository) GetRoleById(ctx context.Context, id int) (Role, error) {
+	var role Role
+
+	err := database.Conn(ctx, r.db).Where(&Role{ID: id}).First(&role).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return role, ErrRoleNotFound
+	}
+	if err != nil {
+		return role, err
+	}
+
+	return role, nil
+}
+
+func (r *repository) CreateRole(ctx context.Context, role Role) (Role, error) {
+	err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		err := checkRoleName(tx, role)
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&role).Error
+	})
+	if err != nil {
+		return role, err
+	}
+
+	return role, nil
+}
+
+func (r *repository) UpdateRole(ctx context.Context, role Role) (Role, error) {
+	err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var existing Role
+		err := tx.Where(&Role{ID: role.ID}).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRoleNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		err = checkRoleName(tx, role)
+		if err != nil {
+			return err
+		}
+
+		existing.Name = role.Name
+		existing.Permissions = role.Permissions
+		err = tx.Save(&existing).Error
+		if err != nil {
+			return err
+		}
+
+		role = existing
+		return nil
+	})
+	if err != nil {
+		return role, err
+	}
+
+	return role, nil
+}
+
+func (r *repository) DeleteRole(ctx context.Context, id int) error {
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Model(&userRole{}).Where("role_id = ?", id).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrRoleInUse
+		}
+
+		result := tx.Delete(&Role{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRoleNotFound
+		}
+		return nil
+	})
+}
+
+func (r *repository) CreateKey(ctx context.Context, key APIKey) (APIKey, error) {
+	err := database.Conn(ctx, r.db).Create(&key).Error
+	if err != nil {
+		return key, err
+	}
+
+	return key, nil
+}
+
+func (r *repository) GetKeys(ctx context.Context, tenantID string) ([]APIKey, error) {
+	var keys []APIKey
+
+	err := database.Conn(ctx, r.db).Where("tenant_id = ?", tenantID).Order("id").Find(&keys).Error
+	if err != nil {
+		return keys, err
+	}
+
+	return keys, nil
+}
+
+func (r *repository) GetKeyByPrefix(ctx context.Context, prefix string) (APIKey, error) {
+	var key APIKey
+
+	err := database.Conn(ctx, r.db).Where("prefix = ?", prefix).First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return key, ErrKeyNotFound
+	}
+	if err != nil {
+		return key, err
+	}
+
+	return key, nil
+}
+
+func (r *repository) RevokeKey(ctx context.Context, tenantID string, id int, at time.Time) (APIKey, error) {
+	var key APIKey
+
+	err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("tenant_id = ?", tenantID).Where(&APIKey{ID: id}).First(&key).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrKeyNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		//revoking twice keeps the first date
+		if key.RevokedAt != nil {
+			return nil
+		}
+		key.RevokedAt = &at
+		return tx.Model(&key).Update("revoked_at", at).Error
+	})
+	if err != nil {
+		return key, err
+	}
+
+	return key, nil
+}
+
+func (r *repository) TouchKey(ctx context.Context, id int, at time.Time) error {
+	//no hook, last_used_at is not a change of the key
+	return database.Conn(ctx, r.db).Model(&APIKey{}).Where("id = ?", id).UpdateColumn("last_used_at", at).Error
+}
+
+// checkRoleName refuses a name taken by another role.
+func checkRoleName(tx *gorm.DB, role Role) error {
+	var count int64
+	err := tx.Model(&Role{}).Where("name = ? AND id <> ?", role.Name, role.ID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrRoleExists
+	}
+	return nil
+}
+
+// assignRoles replaces the roles of a user, and returns their names sorted.
+func assignRoles(tx *gorm.DB, userID int, names []string) ([]string, error) {
+	err := tx.Where("user_id = ?", userID).Delete(&userRole{}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	assigned := []string{}
+	if len(names) == 0 {
+		return assigned, nil
+	}
+
+	var roles []Role
+	err = tx.Where("name IN ?", names).Order("name").Find(&roles).Error
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		found[role.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, ErrUnknownRole.Wrap(fmt.Errorf("%s", name))
+		}
+	}
+
+	for _, role := range roles {
+		err = tx.Create(&userRole{UserID: userID, RoleID: role.ID}).Error
+		if err != nil {
+			return nil, err
+		}
+		assigned = append(assigned, role.Name)
+	}
+	return assigned, nil
+}
+
+// loadRoles fills the role names of users.
+func loadRoles(db *gorm.DB, users []User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(users))
+	index := make(map[int]int, len(users))
+	for i := range users {
+		ids[i] = users[i].ID
+		index[users[i].ID] = i
+		users[i].Roles = []string{}
+	}
+
+	var rows []struct {
+		UserID int
+		Name   string
+	}
+	err := db.Table("user_roles").
+		Select("user_roles.user_id, roles.name").
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Where("user_roles.user_id IN ?", ids).
+		Order("roles.name").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		i := index[row.UserID]
+		users[i].Roles = append(users[i].Roles, row.Name)
+	}
+	return nil
+}
+
+// notFound turns a missing user into ErrUserNotFound.
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+// userTenant restricts a query on users to tenantID, unless it is empty.
+func userTenant(tenantID string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if tenantID == "" {
+			return db
+		}
+		return db.Where("tenant_id = ?", tenantID)
+	}
+}
@@ -0,0 +1,451 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/src/tenant"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/exp/slog"
+)
+
+const minPasswordLength = 8
+
+type Service interface {
+	CreateUser(ctx context.Context, input InputUser) (User, error)
+	GetUsers(ctx context.Context) ([]User, error)
+	SetUserRoles(ctx context.Context, id int, input InputUserRoles) (User, error)
+	Login(ctx context.Context, input InputLogin) (Tokens, error)
+	Refresh(ctx context.Context, input InputRefresh) (Tokens, error)
+	Authenticate(ctx context.Context, accessToken string) (Subject, error)
+	GetRoles(ctx context.Context) ([]Role, error)
+	CreateRole(ctx context.Context, input InputRole) (Role, error)
+	UpdateRole(ctx context.Context, id int, input InputRole) (Role, error)
+	DeleteRole(ctx context.Context, id int) error
+	CreateKey(ctx context.Context, input InputAPIKey) (NewAPIKey, error)
+	GetKeys(ctx context.Context) ([]APIKey, error)
+	RevokeKey(ctx context.Context, id int) (APIKey, error)
+}
+
+type service struct {
+	repository Repository
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+// NewService signs the tokens with secret (HMAC SHA-256), access tokens are
+// valid for accessTTL and refresh tokens for refreshTTL.
+func NewService(r Repository, secret []byte, accessTTL time.Duration, refreshTTL time.Duration) *service {
+	return &service{r, secret, accessTTL, refreshTTL}
+}
+
+func (s *service) CreateUser(ctx context.Context, input InputUser) (User, error) {
+	if len(input.Password) < minPasswordLength {
+		return User{}, ErrWeakPassword
+	}
+	if input.TenantID != "" && !tenant.Valid(input.TenantID) {
+		return User{}, tenant.ErrInvalid
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return User{}, err
+	}
+
+	user, err := s.repository.Create(ctx, User{
+		Username:     input.Username,
+		TenantID:     input.TenantID,
+		PasswordHash: string(hash),
+		Roles:        input.Roles,
+	})
+	if err != nil {
+		return user, err
+	}
+
+	return user, nil
+}
+
+// GetUsers lists the users of the tenant the subject of ctx is bound to, all
+// of them for a subject bound to none.
+func (s *service) GetUsers(ctx context.Context) ([]User, error) {
+	subject, ok := SubjectFrom(ctx)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	return s.repository.GetAll(ctx, subject.Tenant)
+}
+
+// SetUserRoles sets the roles of a user of the tenant the subject of ctx is
+// bound to, the users of other tenants are not found. The subject cannot
+// grant nor remove roles with more than their own permissions.
+func (s *service) SetUserRoles(ctx context.Context, id int, input InputUserRoles) (User, error) {
+	subject, ok := SubjectFrom(ctx)
+	if !ok {
+		return User{}, ErrInvalidToken
+	}
+
+	user, err := s.repository.GetById(ctx, id)
+	if err != nil {
+		return user, err
+	}
+	if subject.Tenant != "" && user.TenantID != subject.Tenant {
+		return User{}, ErrUserNotFound
+	}
+
+	roles, err := s.repository.GetRoles(ctx)
+	if err != nil {
+		return User{}, err
+	}
+	for _, role := range roles {
+		if !contains(input.Roles, role.Name) && !contains(user.Roles, role.Name) {
+			continue
+		}
+		err = checkGrantable(subject, role.Permissions)
+		if err != nil {
+			return User{}, err
+		}
+	}
+
+	return s.repository.SetRoles(ctx, subject.Tenant, id, input.Roles)
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// dummyHash is compared against when the user does not exist, so that the
+// response time does not tell which usernames are taken.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+
+func (s *service) Login(ctx context.Context, input InputLogin) (Tokens, error) {
+	user, err := s.repository.GetByUsername(ctx, input.Username)
+	if errors.Is(err, ErrUserNotFound) {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(input.Password))
+		slog.WarnCtx(ctx, "login refused", "username", input.Username, "reason", "unknown user")
+		return Tokens{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password))
+	if err != nil {
+		slog.WarnCtx(ctx, "login refused", "username", input.Username, "reason", "wrong password")
+		return Tokens{}, ErrInvalidCredentials
+	}
+
+	return s.issue(user)
+}
+
+func (s *service) Refresh(ctx context.Context, input InputRefresh) (Tokens, error) {
+	parsed, err := s.parse(input.RefreshToken, refreshToken)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	//the user may have been removed since the token was issued
+	id, err := strconv.Atoi(parsed.Subject)
+	if err != nil {
+		return Tokens{}, ErrInvalidToken
+	}
+	user, err := s.repository.GetById(ctx, id)
+	if errors.Is(err, ErrUserNotFound) {
+		return Tokens{}, ErrInvalidToken
+	}
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	return s.issue(user)
+}
+
+// Authenticate accepts an access token or an API key.
+func (s *service) Authenticate(ctx context.Context, signed string) (Subject, error) {
+	if IsAPIKey(signed) {
+		return s.authenticateKey(ctx, signed)
+	}
+
+	parsed, err := s.parse(signed, accessToken)
+	if err != nil {
+		return Subject{}, err
+	}
+
+	id, err := strconv.Atoi(parsed.Subject)
+	if err != nil {
+		return Subject{}, ErrInvalidToken
+	}
+
+	//permissions are not in the token, so that role changes apply right away
+	permissions, err := s.repository.GetPermissions(ctx, id)
+	if err != nil {
+		return Subject{}, err
+	}
+
+	return Subject{UserID: id, Username: parsed.Username, Tenant: parsed.Tenant, Permissions: permissions}, nil
+}
+
+func (s *service) GetRoles(ctx context.Context) ([]Role, error) {
+	return s.repository.GetRoles(ctx)
+}
+
+// CreateRole creates a role with at most the permissions of the subject of
+// ctx.
+func (s *service) CreateRole(ctx context.Context, input InputRole) (Role, error) {
+	subject, err := checkRolesEditable(ctx)
+	if err != nil {
+		return Role{}, err
+	}
+
+	err = checkPermissions(input.Permissions)
+	if err != nil {
+		return Role{}, err
+	}
+	err = checkGrantable(subject, input.Permissions)
+	if err != nil {
+		return Role{}, err
+	}
+
+	return s.repository.CreateRole(ctx, Role{
+		Name:        input.Name,
+		Permissions: input.Permissions,
+	})
+}
+
+// UpdateRole sets the permissions of a role to at most the permissions of
+// the subject of ctx.
+func (s *service) UpdateRole(ctx context.Context, id int, input InputRole) (Role, error) {
+	subject, err := checkRolesEditable(ctx)
+	if err != nil {
+		return Role{}, err
+	}
+
+	err = checkPermissions(input.Permissions)
+	if err != nil {
+		return Role{}, err
+	}
+	err = checkGrantable(subject, input.Permissions)
+	if err != nil {
+		return Role{}, err
+	}
+
+	role, err := s.repository.GetRoleById(ctx, id)
+	if err != nil {
+		return role, err
+	}
+	if role.Name == AdminRole {
+		return role, ErrRoleProtected
+	}
+
+	return s.repository.UpdateRole(ctx, Role{
+		ID:          id,
+		Name:        input.Name,
+		Permissions: input.Permissions,
+	})
+}
+
+func (s *service) DeleteRole(ctx context.Context, id int) error {
+	_, err := checkRolesEditable(ctx)
+	if err != nil {
+		return err
+	}
+
+	role, err := s.repository.GetRoleById(ctx, id)
+	if err != nil {
+		return err
+	}
+	if role.Name == AdminRole {
+		return ErrRoleProtected
+	}
+
+	return s.repository.DeleteRole(ctx, id)
+}
+
+// CreateKey creates a key of the tenant of ctx for its subject, who cannot
+// grant it more than their own permissions.
+func (s *service) CreateKey(ctx context.Context, input InputAPIKey) (NewAPIKey, error) {
+	subject, ok := SubjectFrom(ctx)
+	if !ok {
+		return NewAPIKey{}, ErrInvalidToken
+	}
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return NewAPIKey{}, err
+	}
+
+	err = checkPermissions(input.Permissions)
+	if err != nil {
+		return NewAPIKey{}, err
+	}
+	err = checkGrantable(subject, input.Permissions)
+	if err != nil {
+		return NewAPIKey{}, err
+	}
+
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return NewAPIKey{}, ErrInvalidExpiry
+	}
+
+	key, prefix, err := generateKey()
+	if err != nil {
+		return NewAPIKey{}, err
+	}
+
+	created, err := s.repository.CreateKey(ctx, APIKey{
+		UserID:      subject.UserID,
+		TenantID:    tenantID,
+		Name:        input.Name,
+		Prefix:      prefix,
+		Hash:        hashKey(key),
+		Permissions: input.Permissions,
+		ExpiresAt:   input.ExpiresAt,
+	})
+	if err != nil {
+		return NewAPIKey{}, err
+	}
+
+	slog.InfoCtx(ctx, "api key created", "key_id", created.ID, "prefix", created.Prefix)
+	return NewAPIKey{created, key}, nil
+}
+
+// GetKeys lists the keys of the tenant of ctx.
+func (s *service) GetKeys(ctx context.Context) ([]APIKey, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repository.GetKeys(ctx, tenantID)
+}
+
+func (s *service) RevokeKey(ctx context.Context, id int) (APIKey, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	key, err := s.repository.RevokeKey(ctx, tenantID, id, time.Now())
+	if err != nil {
+		return key, err
+	}
+
+	slog.InfoCtx(ctx, "api key revoked", "key_id", key.ID)
+	return key, nil
+}
+
+// authenticateKey looks the key up on every request, so that a revoked key
+// is refused right away.
+func (s *service) authenticateKey(ctx context.Context, key string) (Subject, error) {
+	prefix, ok := splitKey(key)
+	if !ok {
+		return Subject{}, ErrInvalidToken
+	}
+
+	found, err := s.repository.GetKeyByPrefix(ctx, prefix)
+	if errors.Is(err, ErrKeyNotFound) {
+		return Subject{}, ErrInvalidToken
+	}
+	if err != nil {
+		return Subject{}, err
+	}
+
+	now := time.Now()
+	if !keyMatches(key, found.Hash) || found.RevokedAt != nil {
+		return Subject{}, ErrInvalidToken
+	}
+	if found.ExpiresAt != nil && !found.ExpiresAt.After(now) {
+		return Subject{}, ErrInvalidToken
+	}
+
+	if found.LastUsedAt == nil || now.Sub(*found.LastUsedAt) >= keyTouchInterval {
+		err = s.repository.TouchKey(ctx, found.ID, now)
+		if err != nil {
+			return Subject{}, err
+		}
+	}
+
+	//a key keeps only the permissions its owner still has
+	owned, err := s.repository.GetPermissions(ctx, found.UserID)
+	if err != nil {
+		return Subject{}, err
+	}
+
+	return Subject{UserID: found.UserID, KeyID: found.ID, Tenant: found.TenantID, Permissions: intersect(found.Permissions, owned)}, nil
+}
+
+// intersect returns the permissions of granted that owned allows, all of
+// owned when granted has every permission.
+func intersect(granted []Permission, owned []Permission) []Permission {
+	owner := Subject{Permissions: owned}
+	permissions := []Permission{}
+	for _, permission := range granted {
+		if permission == AllPermissions {
+			return owned
+		}
+		if owner.Can(permission) {
+			permissions = append(permissions, permission)
+		}
+	}
+	return permissions
+}
+
+// checkRolesEditable refuses role edits to the subjects bound to a tenant,
+// roles are shared by all the tenants.
+func checkRolesEditable(ctx context.Context) (Subject, error) {
+	subject, ok := SubjectFrom(ctx)
+	if !ok {
+		return subject, ErrInvalidToken
+	}
+	if subject.Tenant != "" {
+		return subject, ErrRolesShared
+	}
+	return subject, nil
+}
+
+// checkGrantable refuses to let subject hand out a permission they were not
+// granted.
+func checkGrantable(subject Subject, permissions []Permission) error {
+	for _, permission := range permissions {
+		if !subject.Can(permission) {
+			return ErrForbidden.Wrap(MissingPermission{permission})
+		}
+	}
+	return nil
+}
+
+func checkPermissions(permissions []Permission) error {
+	for _, permission := range permissions {
+		if !permission.valid() {
+			return ErrUnknownPermission.Wrap(fmt.Errorf("%s", permission))
+		}
+	}
+	return nil
+}
+
+func (s *service) issue(user User) (Tokens, error) {
+	access, err := s.sign(user, accessToken, s.accessTTL)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	refresh, err := s.sign(user, refreshToken, s.refreshTTL)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	return Tokens{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.accessTTL.Seconds()),
+	}, nil
+}
@@ -0,0 +1,87 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"go/src/auth"
+	"testing"
+	"time"
+)
+
+// TestGrantOwnPermissionsOnly checks that a manager of the roles, who was not
+// granted every permission, cannot hand out more than their own.
+func TestGrantOwnPermissionsOnly(t *testing.T) {
+	repository := auth.NewMemoryRepository()
+	service := auth.NewService(repository, []byte("secret"), time.Minute, time.Hour)
+
+	_, err := repository.CreateRole(context.Background(), auth.Role{Name: "manager", Permissions: []auth.Permission{auth.RolesManage}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := createUser(t, repository, "manager", "manager")
+	admin := createUser(t, repository, "root", auth.AdminRole)
+	cashier := createUser(t, repository, "cashier", "cashier")
+	editor := roleNamed(t, repository, "editor")
+
+	ctx := auth.WithSubject(context.Background(), auth.Subject{
+		UserID:      manager.ID,
+		Username:    manager.Username,
+		Permissions: []auth.Permission{auth.RolesManage},
+	})
+	all := []auth.Permission{auth.AllPermissions}
+
+	_, err = service.CreateRole(ctx, auth.InputRole{Name: "root", Permissions: all})
+	wantErr(t, "CreateRole with *", err, auth.ErrForbidden)
+
+	_, err = service.UpdateRole(ctx, editor.ID, auth.InputRole{Name: editor.Name, Permissions: all})
+	wantErr(t, "UpdateRole with *", err, auth.ErrForbidden)
+
+	_, err = service.SetUserRoles(ctx, cashier.ID, auth.InputUserRoles{Roles: []string{auth.AdminRole}})
+	wantErr(t, "SetUserRoles adding admin", err, auth.ErrForbidden)
+
+	_, err = service.SetUserRoles(ctx, admin.ID, auth.InputUserRoles{Roles: []string{}})
+	wantErr(t, "SetUserRoles removing admin", err, auth.ErrForbidden)
+
+	//the manager can still hand out what they have
+	_, err = service.CreateRole(ctx, auth.InputRole{Name: "deputy", Permissions: []auth.Permission{auth.RolesManage}})
+	if err != nil {
+		t.Errorf("CreateRole with roles:manage: %v", err)
+	}
+	_, err = service.SetUserRoles(ctx, cashier.ID, auth.InputUserRoles{Roles: []string{"cashier", "deputy"}})
+	wantErr(t, "SetUserRoles keeping cashier", err, auth.ErrForbidden)
+	_, err = service.SetUserRoles(ctx, manager.ID, auth.InputUserRoles{Roles: []string{"manager", "deputy"}})
+	if err != nil {
+		t.Errorf("SetUserRoles adding deputy: %v", err)
+	}
+}
+
+func createUser(t *testing.T, repository auth.Repository, username string, role string) auth.User {
+	t.Helper()
+	user, err := repository.Create(context.Background(), auth.User{Username: username, Roles: []string{role}})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func roleNamed(t *testing.T, repository auth.Repository, name string) auth.Role {
+	t.Helper()
+	roles, err := repository.GetRoles(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, role := range roles {
+		if role.Name == name {
+			return role
+		}
+	}
+	t.Fatalf("no role %s", name)
+	return auth.Role{}
+}
+
+func wantErr(t *testing.T, what string, err error, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Errorf("%s: got error %v, want %v", what, err, target)
+	}
+}
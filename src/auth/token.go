@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	issuer = "goapi"
+
+	accessToken  = "access"
+	refreshToken = "refresh"
+)
+
+// claims are the claims of the tokens, Type keeps a refresh token from being
+// used as an access token and the other way round.
+type claims struct {
+	Username string `json:"name"`
+	Type     string `json:"typ"`
+	Tenant   string `json:"tid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func (s *service) sign(user User, tokenType string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims{
+		Username: user.Username,
+		Type:     tokenType,
+		Tenant:   user.TenantID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   strconv.Itoa(user.ID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	})
+	return token.SignedString(s.secret)
+}
+
+// parse verifies the signature, expiry and type of a token.
+func (s *service) parse(signed string, tokenType string) (claims, error) {
+	var parsed claims
+
+	_, err := jwt.ParseWithClaims(signed, &parsed, func(token *jwt.Token) (interface{}, error) {
+		return s.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return parsed, ErrInvalidToken.Wrap(err)
+	}
+
+	if parsed.Type != tokenType || !parsed.VerifyIssuer(issuer, true) {
+		return parsed, ErrInvalidToken
+	}
+
+	return parsed, nil
+}
@@ -1,53 +1,112 @@
 package broadcaster
 
 import (
-	"go/src/payment"
+	"sync"
+	"sync/atomic"
 )
 
+// message is an event submitted to the listeners of a topic.
+type message struct {
+	topic string
+	event interface{}
+}
+
+// subscription is a listener of a topic.
+type subscription struct {
+	topic    string
+	listener chan<- interface{}
+}
+
 type broadcaster struct {
-	input chan payment.Payment
-	reg   chan chan<- interface{}
-	unreg chan chan<- interface{}
+	//first, the atomic counters are 64-bit aligned on 32-bit platforms too
+	subscribers int64
+	submitted   uint64
+	dropped     uint64
+	missed      uint64
 
-	outputs map[chan<- interface{}]bool
+	input chan message
+	reg   chan subscription
+	unreg chan subscription
+	done  chan struct{}
+	once  sync.Once
+
+	outputs map[string]map[chan<- interface{}]bool
+}
+
+// Stats counts the listeners and the events of a broadcaster since it
+// started. Dropped events were refused by Submit, missed ones were not
+// delivered to a listener that did not keep up.
+type Stats struct {
+	Subscribers int
+	Queued      int
+	Submitted   uint64
+	Dropped     uint64
+	Missed      uint64
 }
 
+// Broadcaster fans the events submitted to a topic out to every listener
+// registered on that topic, and only to them. Closing it closes the
+// listeners still registered.
 type Broadcaster interface {
-	Register(chan<- interface{})
-	Unregister(chan<- interface{})
+	Register(topic string, listener chan<- interface{})
+	Unregister(topic string, listener chan<- interface{})
 	Close() error
-	Submit(payment.Payment) bool
+	Submit(topic string, event interface{}) bool
+	Running() bool
+	Stats() Stats
 }
 
-func (bc *broadcaster) broadcast(payment payment.Payment) {
-	for listener := range bc.outputs {
-		listener <- payment
+func (bc *broadcaster) broadcast(m message) {
+	for listener := range bc.outputs[m.topic] {
+		select {
+		case listener <- m.event:
+		default:
+			//a listener that does not keep up must not block the others
+			atomic.AddUint64(&bc.missed, 1)
+		}
 	}
 }
 
 func (bc *broadcaster) run() {
 	for {
 		select {
-		case pm := <-bc.input:
-			bc.broadcast(pm)
-		case ch, ok := <-bc.reg:
-			if ok {
-				bc.outputs[ch] = true
-			} else {
-				return
+		case m := <-bc.input:
+			bc.broadcast(m)
+		case s := <-bc.reg:
+			if bc.outputs[s.topic] == nil {
+				bc.outputs[s.topic] = make(map[chan<- interface{}]bool)
+			}
+			if !bc.outputs[s.topic][s.listener] {
+				atomic.AddInt64(&bc.subscribers, 1)
+			}
+			bc.outputs[s.topic][s.listener] = true
+		case s := <-bc.unreg:
+			if bc.outputs[s.topic][s.listener] {
+				atomic.AddInt64(&bc.subscribers, -1)
+			}
+			delete(bc.outputs[s.topic], s.listener)
+			if len(bc.outputs[s.topic]) == 0 {
+				delete(bc.outputs, s.topic)
 			}
-		case ch := <-bc.unreg:
-			delete(bc.outputs, ch)
+		case <-bc.done:
+			for _, listeners := range bc.outputs {
+				for ch := range listeners {
+					close(ch)
+				}
+			}
+			atomic.StoreInt64(&bc.subscribers, 0)
+			return
 		}
 	}
 }
 
 func NewBroadcaster(buflen int) Broadcaster {
 	bc := &broadcaster{
-		input:   make(chan payment.Payment, buflen),
-		reg:     make(chan chan<- interface{}),
-		unreg:   make(chan chan<- interface{}),
-		outputs: make(map[chan<- interface{}]bool),
+		input:   make(chan message, buflen),
+		reg:     make(chan subscription),
+		unreg:   make(chan subscription),
+		done:    make(chan struct{}),
+		outputs: make(map[string]map[chan<- interface{}]bool),
 	}
 
 	go bc.run()
@@ -55,28 +114,64 @@ func NewBroadcaster(buflen int) Broadcaster {
 	return bc
 }
 
-func (bc *broadcaster) Register(newch chan<- interface{}) {
-	bc.reg <- newch
+func (bc *broadcaster) Register(topic string, newch chan<- interface{}) {
+	select {
+	case bc.reg <- subscription{topic, newch}:
+	case <-bc.done:
+		close(newch)
+	}
+}
+
+func (bc *broadcaster) Unregister(topic string, newch chan<- interface{}) {
+	select {
+	case bc.unreg <- subscription{topic, newch}:
+	case <-bc.done:
+	}
 }
 
-func (bc *broadcaster) Unregister(newch chan<- interface{}) {
-	bc.unreg <- newch
+// Running tells whether the broadcaster still delivers events.
+func (bc *broadcaster) Running() bool {
+	select {
+	case <-bc.done:
+		return false
+	default:
+		return true
+	}
 }
 
 func (bc *broadcaster) Close() error {
-	close(bc.reg)
-	close(bc.unreg)
+	bc.once.Do(func() {
+		close(bc.done)
+	})
 	return nil
 }
 
-func (bc *broadcaster) Submit(payment payment.Payment) bool {
+func (bc *broadcaster) Submit(topic string, event interface{}) bool {
 	if bc == nil {
 		return false
 	}
 	select {
-	case bc.input <- payment:
+	case <-bc.done:
+		return false
+	default:
+	}
+
+	select {
+	case bc.input <- message{topic, event}:
+		atomic.AddUint64(&bc.submitted, 1)
 		return true
 	default:
+		atomic.AddUint64(&bc.dropped, 1)
 		return false
 	}
 }
+
+func (bc *broadcaster) Stats() Stats {
+	return Stats{
+		Subscribers: int(atomic.LoadInt64(&bc.subscribers)),
+		Queued:      len(bc.input),
+		Submitted:   atomic.LoadUint64(&bc.submitted),
+		Dropped:     atomic.LoadUint64(&bc.dropped),
+		Missed:      atomic.LoadUint64(&bc.missed),
+	}
+}
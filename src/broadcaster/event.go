@@ -0,0 +1,20 @@
+package broadcaster
+
+import (
+	"context"
+	"go/src/request"
+)
+
+// Event is a change submitted by the API, with the ID of the request that
+// made it, for clients to match what they receive with their requests and
+// with the logs.
+type Event struct {
+	RequestID string
+	Data      interface{}
+}
+
+// NewEvent is the event of data changed by the request of ctx.
+func NewEvent(ctx context.Context, data interface{}) Event {
+	info, _ := request.FromContext(ctx)
+	return Event{RequestID: info.ID, Data: data}
+}
@@ -0,0 +1,463 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config holds every setting of the API. Each one is read, in increasing
+// order of precedence, from the defaults, the YAML file given by -config (or
+// GOAPI_CONFIG), GOAPI_* environment variables and command line flags.
+type Config struct {
+	HTTP        HTTP        `yaml:"http"`
+	Database    Database    `yaml:"database"`
+	Broadcaster Broadcaster `yaml:"broadcaster"`
+	Products    Products    `yaml:"products"`
+	Auth        Auth        `yaml:"auth"`
+	RateLimit   RateLimit   `yaml:"rate_limit"`
+	Log         Log         `yaml:"log"`
+	Tracing     Tracing     `yaml:"tracing"`
+}
+
+// HTTP serves the API on Addr and the metrics, apart from it, on
+// MetricsAddr. The client IP is read from X-Forwarded-For only when the
+// request comes from one of TrustedProxies.
+type HTTP struct {
+	Addr           string        `yaml:"addr"`
+	MetricsAddr    string        `yaml:"metrics_addr"`
+	TrustedProxies []string      `yaml:"trusted_proxies"`
+	ShutdownDelay  time.Duration `yaml:"shutdown_delay"`
+	DrainTimeout   time.Duration `yaml:"drain_timeout"`
+}
+
+type Database struct {
+	Driver       string        `yaml:"driver"`
+	Host         string        `yaml:"host"`
+	Port         int           `yaml:"port"`
+	User         string        `yaml:"user"`
+	Password     string        `yaml:"password"`
+	PasswordFile string        `yaml:"password_file"`
+	Name         string        `yaml:"name"`
+	Params       string        `yaml:"params"`
+	QueryTimeout time.Duration `yaml:"query_timeout"`
+}
+
+type Broadcaster struct {
+	Buffer int `yaml:"buffer"`
+}
+
+type Products struct {
+	DeletePolicy  string        `yaml:"delete_policy"`
+	PriceInterval time.Duration `yaml:"price_interval"`
+}
+
+type Auth struct {
+	Secret        string        `yaml:"secret"`
+	SecretFile    string        `yaml:"secret_file"`
+	AccessTTL     time.Duration `yaml:"access_ttl"`
+	RefreshTTL    time.Duration `yaml:"refresh_ttl"`
+	AdminUsername string        `yaml:"admin_username"`
+	AdminPassword string        `yaml:"admin_password"`
+}
+
+// RateLimit holds the limits of each route group, per client. Admin covers
+// the roles, users, keys and audit routes. Credentials limits, per IP, the
+// requests whose credentials are checked, valid or not.
+type RateLimit struct {
+	Auth              Limit `yaml:"auth"`
+	Credentials       Limit `yaml:"credentials"`
+	Products          Limit `yaml:"products"`
+	Payments          Limit `yaml:"payments"`
+	Admin             Limit `yaml:"admin"`
+	StreamConnections int   `yaml:"stream_connections"`
+}
+
+// Limit allows Requests per Period, 0 requests for no limit.
+type Limit struct {
+	Requests int           `yaml:"requests"`
+	Period   time.Duration `yaml:"period"`
+}
+
+type Log struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+// Tracing sends the spans to the OTLP/HTTP collector at Endpoint, writes
+// them to the standard output (stdout), or drops them (none).
+type Tracing struct {
+	Exporter    string `yaml:"exporter"`
+	Endpoint    string `yaml:"endpoint"`
+	ServiceName string `yaml:"service_name"`
+}
+
+// minSecretLength is the size of the SHA-256 HMAC key, in bytes.
+const minSecretLength = 32
+
+func Default() Config {
+	return Config{
+		HTTP: HTTP{
+			Addr:         ":3333",
+			MetricsAddr:  "localhost:9464",
+			DrainTimeout: 15 * time.Second,
+		},
+		Database: Database{
+			Driver:       "mysql",
+			Host:         "127.0.0.1",
+			User:         "user",
+			Name:         "goapi",
+			QueryTimeout: 10 * time.Second,
+		},
+		Broadcaster: Broadcaster{
+			Buffer: 10,
+		},
+		Products: Products{
+			DeletePolicy:  "archive",
+			PriceInterval: time.Minute,
+		},
+		Auth: Auth{
+			AccessTTL:     15 * time.Minute,
+			RefreshTTL:    7 * 24 * time.Hour,
+			AdminUsername: "admin",
+		},
+		RateLimit: RateLimit{
+			Auth:              Limit{Requests: 10, Period: time.Minute},
+			Credentials:       Limit{Requests: 1200, Period: time.Minute},
+			Products:          Limit{Requests: 600, Period: time.Minute},
+			Payments:          Limit{Requests: 300, Period: time.Minute},
+			Admin:             Limit{Requests: 60, Period: time.Minute},
+			StreamConnections: 5,
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			Endpoint:    "http://localhost:4318",
+			ServiceName: "goapi",
+		},
+	}
+}
+
+// DSN is the data source name of the database for its driver. With SQLite,
+// name is the path of the database file.
+func (d Database) DSN() string {
+	switch d.Driver {
+	case "postgres":
+		port := d.Port
+		if port == 0 {
+			port = 5432
+		}
+		dsn := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(d.User, d.Password),
+			Host:     net.JoinHostPort(d.Host, strconv.Itoa(port)),
+			Path:     d.Name,
+			RawQuery: d.Params,
+		}
+		return dsn.String()
+	case "sqlite":
+		//writers take the database lock when they begin, SQLite has no row locks
+		params := "_foreign_keys=on&_busy_timeout=5000&_txlock=immediate"
+		if d.Params != "" {
+			params += "&" + d.Params
+		}
+		return "file:" + d.Name + "?" + params
+	default:
+		port := d.Port
+		if port == 0 {
+			//the port of the docker-compose database
+			port = 3309
+		}
+		params := "charset=utf8mb4&parseTime=True&loc=Local"
+		if d.Params != "" {
+			params += "&" + d.Params
+		}
+		return fmt.Sprintf("%s:%s@tcp(%s)/%s?%s", d.User, d.Password, net.JoinHostPort(d.Host, strconv.Itoa(port)), d.Name, params)
+	}
+}
+
+// setting binds a key of the YAML file to its environment variable and flag:
+// database.password_file is GOAPI_DATABASE_PASSWORD_FILE and
+// -database-password-file.
+type setting struct {
+	key    string
+	usage  string
+	target interface{}
+}
+
+func (s setting) env() string {
+	return "GOAPI_" + strings.ToUpper(strings.NewReplacer(".", "_").Replace(s.key))
+}
+
+func (s setting) flag() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(s.key)
+}
+
+func (s setting) set(value string) error {
+	var err error
+	switch target := s.target.(type) {
+	case *string:
+		*target = value
+	case *int:
+		*target, err = strconv.Atoi(value)
+	case *time.Duration:
+		*target, err = time.ParseDuration(value)
+	case *[]string:
+		*target = nil
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if item != "" {
+				*target = append(*target, item)
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.key, err)
+	}
+	return nil
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"http.addr", "address the HTTP server listens on", &c.HTTP.Addr},
+		{"http.metrics_addr", "internal address /metrics is served on, empty to not serve it", &c.HTTP.MetricsAddr},
+		{"http.trusted_proxies", "comma separated IPs or CIDRs of the proxies X-Forwarded-For is read from, none by default", &c.HTTP.TrustedProxies},
+		{"http.shutdown_delay", "how long to keep serving, reported as not ready, before shutting down", &c.HTTP.ShutdownDelay},
+		{"http.drain_timeout", "how long in-flight requests may take to finish on shutdown", &c.HTTP.DrainTimeout},
+		{"database.driver", "mysql, postgres, sqlite or memory", &c.Database.Driver},
+		{"database.host", "database host", &c.Database.Host},
+		{"database.port", "database port, 0 for the default of the driver", &c.Database.Port},
+		{"database.user", "database user", &c.Database.User},
+		{"database.password", "database password, prefer database.password_file", &c.Database.Password},
+		{"database.password_file", "file holding the database password", &c.Database.PasswordFile},
+		{"database.name", "database name, or file with sqlite", &c.Database.Name},
+		{"database.params", "DSN parameters, added to the defaults of the driver", &c.Database.Params},
+		{"database.query_timeout", "how long a query may run before it is cancelled, 0 for no limit", &c.Database.QueryTimeout},
+		{"broadcaster.buffer", "number of events queued for the stream", &c.Broadcaster.Buffer},
+		{"products.delete_policy", "block, archive or cascade the payments of a deleted product", &c.Products.DeletePolicy},
+		{"products.price_interval", "how often scheduled prices are applied", &c.Products.PriceInterval},
+		{"auth.secret", "key signing the tokens, at least 32 bytes, prefer auth.secret_file", &c.Auth.Secret},
+		{"auth.secret_file", "file holding the key signing the tokens", &c.Auth.SecretFile},
+		{"auth.access_ttl", "how long an access token is valid", &c.Auth.AccessTTL},
+		{"auth.refresh_ttl", "how long a refresh token is valid", &c.Auth.RefreshTTL},
+		{"auth.admin_username", "user created at startup when auth.admin_password is set", &c.Auth.AdminUsername},
+		{"auth.admin_password", "password of auth.admin_username if it does not exist yet", &c.Auth.AdminPassword},
+		{"rate_limit.auth.requests", "login and refresh requests per period and IP, 0 for no limit", &c.RateLimit.Auth.Requests},
+		{"rate_limit.auth.period", "period of rate_limit.auth.requests", &c.RateLimit.Auth.Period},
+		{"rate_limit.credentials.requests", "requests with a token or an API key per period and IP, checked before the credential, 0 for no limit", &c.RateLimit.Credentials.Requests},
+		{"rate_limit.credentials.period", "period of rate_limit.credentials.requests", &c.RateLimit.Credentials.Period},
+		{"rate_limit.products.requests", "product requests per period and client, 0 for no limit", &c.RateLimit.Products.Requests},
+		{"rate_limit.products.period", "period of rate_limit.products.requests", &c.RateLimit.Products.Period},
+		{"rate_limit.payments.requests", "payment requests per period and client, 0 for no limit", &c.RateLimit.Payments.Requests},
+		{"rate_limit.payments.period", "period of rate_limit.payments.requests", &c.RateLimit.Payments.Period},
+		{"rate_limit.admin.requests", "role, user, key and audit requests per period and client, 0 for no limit", &c.RateLimit.Admin.Requests},
+		{"rate_limit.admin.period", "period of rate_limit.admin.requests", &c.RateLimit.Admin.Period},
+		{"rate_limit.stream_connections", "streams a client may hold open at once, 0 for no limit", &c.RateLimit.StreamConnections},
+		{"log.level", "debug, info, warn or error", &c.Log.Level},
+		{"log.format", "json or text", &c.Log.Format},
+		{"tracing.exporter", "otlp, stdout or none", &c.Tracing.Exporter},
+		{"tracing.endpoint", "URL of the OTLP/HTTP collector, with tracing.exporter otlp", &c.Tracing.Endpoint},
+		{"tracing.service_name", "service.name of the spans", &c.Tracing.ServiceName},
+	}
+}
+
+// Load builds the configuration from args (without the program name), the
+// environment and the optional configuration file, then validates it.
+func Load(args []string) (Config, error) {
+	return LoadFlags(flag.NewFlagSet("goapi", flag.ContinueOnError), args)
+}
+
+// LoadFlags is Load for a command with flags of its own: they are parsed
+// along with the settings, the remaining arguments are in flags.Args().
+func LoadFlags(flags *flag.FlagSet, args []string) (Config, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	path := flags.String("config", os.Getenv("GOAPI_CONFIG"), "YAML configuration file")
+	values := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		values[s.flag()] = flags.String(s.flag(), "", s.usage+" ("+s.env()+")")
+	}
+
+	err := flags.Parse(args)
+	if err != nil {
+		return cfg, err
+	}
+
+	if *path != "" {
+		content, err := os.ReadFile(*path)
+		if err != nil {
+			return cfg, err
+		}
+
+		err = yaml.UnmarshalStrict(content, &cfg)
+		if err != nil {
+			return cfg, fmt.Errorf("%s: %w", *path, err)
+		}
+	}
+
+	for _, s := range settings {
+		value, ok := os.LookupEnv(s.env())
+		if !ok {
+			continue
+		}
+
+		err = s.set(value)
+		if err != nil {
+			return cfg, err
+		}
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if err == nil && s.flag() == f.Name {
+				err = s.set(*values[f.Name])
+			}
+		}
+	})
+	if err != nil {
+		return cfg, err
+	}
+
+	if cfg.Database.PasswordFile != "" {
+		secret, err := os.ReadFile(cfg.Database.PasswordFile)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Database.Password = strings.TrimRight(string(secret), "\r\n")
+	}
+
+	if cfg.Auth.SecretFile != "" {
+		secret, err := os.ReadFile(cfg.Auth.SecretFile)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Auth.Secret = strings.TrimRight(string(secret), "\r\n")
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate reports every invalid setting at once.
+func (c Config) Validate() error {
+	var errs []string
+
+	if c.HTTP.Addr == "" {
+		errs = append(errs, "http.addr is required")
+	}
+	for _, proxy := range c.HTTP.TrustedProxies {
+		_, _, err := net.ParseCIDR(proxy)
+		if err != nil && net.ParseIP(proxy) == nil {
+			errs = append(errs, "http.trusted_proxies must be IPs or CIDRs")
+			break
+		}
+	}
+	if c.HTTP.ShutdownDelay < 0 {
+		errs = append(errs, "http.shutdown_delay must not be negative")
+	}
+	if c.HTTP.DrainTimeout <= 0 {
+		errs = append(errs, "http.drain_timeout must be positive")
+	}
+	switch c.Database.Driver {
+	case "mysql", "postgres":
+		if c.Database.Host == "" {
+			errs = append(errs, "database.host is required")
+		}
+		if c.Database.Port < 0 || c.Database.Port > 65535 {
+			errs = append(errs, "database.port must be between 1 and 65535, or 0 for the default")
+		}
+		if c.Database.User == "" {
+			errs = append(errs, "database.user is required")
+		}
+	case "sqlite", "memory":
+	default:
+		errs = append(errs, "database.driver must be mysql, postgres, sqlite or memory")
+	}
+	if c.Database.Name == "" && c.Database.Driver != "memory" {
+		errs = append(errs, "database.name is required")
+	}
+	if c.Database.QueryTimeout < 0 {
+		errs = append(errs, "database.query_timeout must not be negative")
+	}
+	if c.Broadcaster.Buffer < 1 {
+		errs = append(errs, "broadcaster.buffer must be positive")
+	}
+	switch c.Products.DeletePolicy {
+	case "block", "archive", "cascade":
+	default:
+		errs = append(errs, "products.delete_policy must be block, archive or cascade")
+	}
+	if c.Products.PriceInterval <= 0 {
+		errs = append(errs, "products.price_interval must be positive")
+	}
+	if c.Auth.Secret != "" && len(c.Auth.Secret) < minSecretLength {
+		errs = append(errs, fmt.Sprintf("auth.secret must be at least %d bytes long", minSecretLength))
+	}
+	if c.Auth.AdminPassword != "" && c.Auth.AdminUsername == "" {
+		errs = append(errs, "auth.admin_username is required with auth.admin_password")
+	}
+	if c.Auth.AccessTTL <= 0 {
+		errs = append(errs, "auth.access_ttl must be positive")
+	}
+	if c.Auth.RefreshTTL <= 0 {
+		errs = append(errs, "auth.refresh_ttl must be positive")
+	}
+	limits := map[string]Limit{
+		"auth":        c.RateLimit.Auth,
+		"credentials": c.RateLimit.Credentials,
+		"products":    c.RateLimit.Products,
+		"payments":    c.RateLimit.Payments,
+		"admin":       c.RateLimit.Admin,
+	}
+	for _, group := range []string{"auth", "credentials", "products", "payments", "admin"} {
+		limit := limits[group]
+		if limit.Requests < 0 {
+			errs = append(errs, fmt.Sprintf("rate_limit.%s.requests must not be negative", group))
+		}
+		if limit.Requests > 0 && limit.Period <= 0 {
+			errs = append(errs, fmt.Sprintf("rate_limit.%s.period must be positive", group))
+		}
+	}
+	if c.RateLimit.StreamConnections < 0 {
+		errs = append(errs, "rate_limit.stream_connections must not be negative")
+	}
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, "log.level must be debug, info, warn or error")
+	}
+	switch c.Log.Format {
+	case "json", "text":
+	default:
+		errs = append(errs, "log.format must be json or text")
+	}
+	switch c.Tracing.Exporter {
+	case "otlp":
+		endpoint, err := url.Parse(c.Tracing.Endpoint)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			errs = append(errs, "tracing.endpoint must be an http or https URL")
+		}
+	case "stdout", "none":
+	default:
+		errs = append(errs, "tracing.exporter must be otlp, stdout or none")
+	}
+	if c.Tracing.ServiceName == "" {
+		errs = append(errs, "tracing.service_name is required")
+	}
+
+	if len(errs) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(errs, ", "))
+	}
+	return nil
+}
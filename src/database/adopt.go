@@ -0,0 +1,68 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// adoptedColumn is a column the first migrations create that the tables of
+// a database made by AutoMigrate, before them, may lack.
+type adoptedColumn struct {
+	table      string
+	column     string
+	definition map[string]string
+}
+
+var adoptedColumns = []adoptedColumn{
+	{"products", "version", map[string]string{MySQL: "BIGINT NOT NULL DEFAULT 1", Postgres: "BIGINT NOT NULL DEFAULT 1", SQLite: "INTEGER NOT NULL DEFAULT 1"}},
+	{"products", "deleted_at", map[string]string{MySQL: "DATETIME(3) NULL", Postgres: "TIMESTAMPTZ", SQLite: "DATETIME"}},
+	{"payments", "version", map[string]string{MySQL: "BIGINT NOT NULL DEFAULT 1", Postgres: "BIGINT NOT NULL DEFAULT 1", SQLite: "INTEGER NOT NULL DEFAULT 1"}},
+	{"payments", "deleted_at", map[string]string{MySQL: "DATETIME(3) NULL", Postgres: "TIMESTAMPTZ", SQLite: "DATETIME"}},
+}
+
+// adoptedIndex is an index the first migrations of MySQL create along with
+// their table, the other dialects create them apart with IF NOT EXISTS.
+type adoptedIndex struct {
+	table  string
+	name   string
+	create string
+}
+
+var adoptedIndexes = []adoptedIndex{
+	{"products", "idx_products_deleted_at", "CREATE INDEX idx_products_deleted_at ON products (deleted_at)"},
+	{"products", "idx_products_name_search", "CREATE FULLTEXT INDEX idx_products_name_search ON products (name)"},
+	{"payments", "idx_payments_deleted_at", "CREATE INDEX idx_payments_deleted_at ON payments (deleted_at)"},
+}
+
+// adopt brings the tables a previous version created with AutoMigrate to
+// the schema of the first migrations, whose CREATE TABLE IF NOT EXISTS
+// keeps them as they are. It runs before the first migration only.
+func adopt(db *gorm.DB) error {
+	dialect := Dialect(db)
+	migrator := db.Migrator()
+
+	for _, column := range adoptedColumns {
+		if !migrator.HasTable(column.table) || migrator.HasColumn(column.table, column.column) {
+			continue
+		}
+		err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", column.table, column.column, column.definition[dialect])).Error
+		if err != nil {
+			return fmt.Errorf("adopt %s.%s: %w", column.table, column.column, err)
+		}
+	}
+
+	if dialect != MySQL {
+		return nil
+	}
+	for _, index := range adoptedIndexes {
+		if !migrator.HasTable(index.table) || migrator.HasIndex(index.table, index.name) {
+			continue
+		}
+		err := db.Exec(index.create).Error
+		if err != nil {
+			return fmt.Errorf("adopt %s: %w", index.name, err)
+		}
+	}
+	return nil
+}
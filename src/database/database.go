@@ -0,0 +1,93 @@
+package database
+
+import (
+	"fmt"
+	"go/src/config"
+	"go/src/logging"
+
+	"golang.org/x/exp/slog"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Names of the dialects, as returned by gorm.Dialector.Name().
+const (
+	MySQL    = "mysql"
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+// Open connects to the database of the driver set in the configuration.
+func Open(cfg config.Database) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+
+	switch cfg.Driver {
+	case MySQL:
+		dialector = mysql.Open(cfg.DSN())
+	case Postgres:
+		dialector = postgres.Open(cfg.DSN())
+	case SQLite:
+		dialector = sqlite.Open(cfg.DSN())
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", cfg.Driver)
+	}
+
+	return gorm.Open(dialector, &gorm.Config{Logger: logging.GORM(slog.Default())})
+}
+
+// Dialect is the name of the dialect db talks.
+func Dialect(db *gorm.DB) string {
+	return db.Dialector.Name()
+}
+
+// ForShare locks the selected rows against writes until the end of the
+// transaction.
+func ForShare(db *gorm.DB) *gorm.DB {
+	return lock(db, "SHARE")
+}
+
+// ForUpdate locks the selected rows until the end of the transaction.
+func ForUpdate(db *gorm.DB) *gorm.DB {
+	return lock(db, "UPDATE")
+}
+
+func lock(db *gorm.DB, strength string) *gorm.DB {
+	//SQLite has no row locks, its transactions take the database lock when
+	//they begin (_txlock=immediate) so they are serialized instead
+	if Dialect(db) == SQLite {
+		return db
+	}
+	return db.Clauses(clause.Locking{Strength: strength})
+}
+
+// Reindex rebuilds the indexes of the tables, the full-text one included,
+// and refreshes the statistics of the query planner.
+func Reindex(db *gorm.DB) error {
+	var statements []string
+
+	switch Dialect(db) {
+	case MySQL:
+		//InnoDB rebuilds the table and its indexes
+		statements = []string{"OPTIMIZE TABLE products, product_prices, payments"}
+	case Postgres:
+		statements = []string{
+			"REINDEX TABLE products",
+			"REINDEX TABLE product_prices",
+			"REINDEX TABLE payments",
+			"ANALYZE products, product_prices, payments",
+		}
+	case SQLite:
+		statements = []string{"REINDEX", "ANALYZE"}
+	}
+
+	for _, statement := range statements {
+		err := db.Exec(statement).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
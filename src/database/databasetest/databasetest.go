@@ -0,0 +1,46 @@
+// Package databasetest opens migrated databases for the tests of the
+// repositories.
+package databasetest
+
+import (
+	"go/src/config"
+	"go/src/database"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// Open returns a SQLite database held in memory with every migration
+// applied, closed when the test ends.
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	db := OpenEmpty(t)
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		t.Fatalf("read migrations: %v", err)
+	}
+	_, err = migrator.Up()
+	if err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	return db
+}
+
+// OpenEmpty is Open without the migrations.
+func OpenEmpty(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	db, err := database.Open(config.Database{Driver: database.SQLite, Name: ":memory:"})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	//each connection to file::memory: opens a database of its own
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
@@ -0,0 +1,335 @@
+package database
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationFiles holds the SQL migrations of every dialect, named
+// <version>_<name>.up.sql and <version>_<name>.down.sql.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// ErrNotMigrated is returned when migrations are still to be applied.
+var ErrNotMigrated = errors.New("database is not migrated")
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// schemaMigration is a row of the schema_migrations table, one per applied
+// migration.
+type schemaMigration struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrator moves the schema of a database between the versions of its
+// migrations, recording the applied ones in schema_migrations.
+type Migrator interface {
+	Status() ([]MigrationStatus, error)
+	Pending() ([]Migration, error)
+	Check() error
+	Up() ([]Migration, error)
+	Down() ([]Migration, error)
+	To(version int) ([]Migration, error)
+}
+
+type migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewMigrator reads the migrations of the dialect of db.
+func NewMigrator(db *gorm.DB) (Migrator, error) {
+	migrations, err := readMigrations(Dialect(db))
+	if err != nil {
+		return nil, err
+	}
+	return &migrator{db, migrations}, nil
+}
+
+func readMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s: %w", dialect, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		name, direction := strings.TrimSuffix(name, path.Ext(name)), path.Ext(name)
+
+		number, label, ok := strings.Cut(name, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil || (direction != ".up" && direction != ".down") {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+
+		content, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: label}
+			byVersion[version] = migration
+		}
+		if direction == ".up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d misses its up or down file", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+func (m *migrator) init() error {
+	return m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (version)
+	)`).Error
+}
+
+func (m *migrator) applied() (map[int]schemaMigration, error) {
+	//a database never migrated has no schema_migrations table yet
+	if !m.db.Migrator().HasTable(schemaMigration{}.TableName()) {
+		return map[int]schemaMigration{}, nil
+	}
+
+	var rows []schemaMigration
+	err := m.db.Order("version").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]schemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// Status lists every migration, with the time it was applied at.
+func (m *migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		status[i].Migration = migration
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			status[i].AppliedAt = &appliedAt
+		}
+	}
+	return status, nil
+}
+
+// Pending lists the migrations still to be applied.
+func (m *migrator) Pending() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Check returns ErrNotMigrated when migrations are pending.
+func (m *migrator) Check() error {
+	pending, err := m.Pending()
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %d migrations pending, run migrate up", ErrNotMigrated, len(pending))
+	}
+	return nil
+}
+
+// latest is the version of the last migration.
+func (m *migrator) latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies every pending migration.
+func (m *migrator) Up() ([]Migration, error) {
+	return m.To(m.latest())
+}
+
+// Down rolls back the last applied migration.
+func (m *migrator) Down() ([]Migration, error) {
+	err := m.init()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		if _, ok := applied[m.migrations[i].Version]; ok {
+			err = m.down(m.migrations[i])
+			if err != nil {
+				return nil, err
+			}
+			return m.migrations[i : i+1], nil
+		}
+	}
+	return nil, nil
+}
+
+// To applies the migrations up to version and rolls back the ones after it,
+// returning them in the order they were run.
+func (m *migrator) To(version int) ([]Migration, error) {
+	if version != 0 && !m.exists(version) {
+		return nil, fmt.Errorf("unknown migration %d", version)
+	}
+
+	err := m.init()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok || migration.Version <= version {
+			continue
+		}
+
+		err = m.down(migration)
+		if err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+
+	//a database of a version before the migrations is adopted by the first
+	if len(applied) == 0 && version > 0 {
+		err = adopt(m.db)
+		if err != nil {
+			return done, err
+		}
+	}
+
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok || migration.Version > version {
+			continue
+		}
+
+		err = m.up(migration)
+		if err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+func (m *migrator) up(migration Migration) error {
+	err := m.run(migration.Up, func(tx *gorm.DB) error {
+		return tx.Create(&schemaMigration{migration.Version, migration.Name, time.Now()}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d up: %w", migration.Version, err)
+	}
+	return nil
+}
+
+func (m *migrator) down(migration Migration) error {
+	err := m.run(migration.Down, func(tx *gorm.DB) error {
+		return tx.Delete(&schemaMigration{}, "version = ?", migration.Version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d down: %w", migration.Version, err)
+	}
+	return nil
+}
+
+func (m *migrator) exists(version int) bool {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// run executes the statements of script then record in one transaction.
+// MySQL commits DDL statements on its own, a failed migration has to be
+// fixed by hand there.
+func (m *migrator) run(script string, record func(tx *gorm.DB) error) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range strings.Split(script, ";") {
+			if strings.TrimSpace(statement) == "" {
+				continue
+			}
+			err := tx.Exec(statement).Error
+			if err != nil {
+				return err
+			}
+		}
+		return record(tx)
+	})
+}
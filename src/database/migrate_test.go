@@ -0,0 +1,121 @@
+package database_test
+
+import (
+	"go/src/database"
+	"go/src/database/databasetest"
+	"testing"
+	"time"
+)
+
+func TestMigrations(t *testing.T) {
+	db := databasetest.Open(t)
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = migrator.Check()
+	if err != nil {
+		t.Fatalf("check after up: %v", err)
+	}
+
+	//every migration rolls back and applies again
+	_, err = migrator.To(0)
+	if err != nil {
+		t.Fatalf("roll back: %v", err)
+	}
+	pending, err := migrator.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := migrator.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != len(status) {
+		t.Fatalf("%d migrations pending after rolling back, want %d", len(pending), len(status))
+	}
+
+	_, err = migrator.Up()
+	if err != nil {
+		t.Fatalf("apply again: %v", err)
+	}
+	err = migrator.Check()
+	if err != nil {
+		t.Fatalf("check after up: %v", err)
+	}
+}
+
+// baselineProduct and baselinePayment are the models AutoMigrate created the
+// tables of, before the migrations.
+type baselineProduct struct {
+	ID        int
+	Name      string
+	Price     float64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (baselineProduct) TableName() string {
+	return "products"
+}
+
+type baselinePayment struct {
+	ID        int
+	ProductID int
+	Product   *baselineProduct
+	PricePaid float64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (baselinePayment) TableName() string {
+	return "payments"
+}
+
+// TestAdoptBaseline checks that the migrations take over a database created
+// by AutoMigrate, keeping its rows.
+func TestAdoptBaseline(t *testing.T) {
+	db := databasetest.OpenEmpty(t)
+	err := db.AutoMigrate(&baselinePayment{}, &baselineProduct{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	product := baselineProduct{Name: "chair", Price: 10}
+	err = db.Create(&product).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Create(&baselinePayment{ProductID: product.ID, PricePaid: 10}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = migrator.Up()
+	if err != nil {
+		t.Fatalf("migrate baseline: %v", err)
+	}
+	err = migrator.Check()
+	if err != nil {
+		t.Fatalf("check after up: %v", err)
+	}
+
+	for _, table := range []string{"products", "payments"} {
+		var rows []struct {
+			Version   int
+			DeletedAt *time.Time
+			TenantID  string
+		}
+		err = db.Table(table).Select("version, deleted_at, tenant_id").Find(&rows).Error
+		if err != nil {
+			t.Fatalf("%s: %v", table, err)
+		}
+		if len(rows) != 1 || rows[0].Version != 1 || rows[0].DeletedAt != nil || rows[0].TenantID != "default" {
+			t.Errorf("%s: got %+v, want the baseline row in version 1 of the default tenant", table, rows)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS products (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	name LONGTEXT,
+	price DOUBLE,
+	version BIGINT NOT NULL DEFAULT 1,
+	created_at DATETIME(3) NULL,
+	updated_at DATETIME(3) NULL,
+	deleted_at DATETIME(3) NULL,
+	PRIMARY KEY (id),
+	INDEX idx_products_deleted_at (deleted_at),
+	FULLTEXT INDEX idx_products_name_search (name)
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS payments (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	product_id BIGINT,
+	price_paid DOUBLE,
+	version BIGINT NOT NULL DEFAULT 1,
+	created_at DATETIME(3) NULL,
+	updated_at DATETIME(3) NULL,
+	deleted_at DATETIME(3) NULL,
+	PRIMARY KEY (id),
+	INDEX idx_payments_deleted_at (deleted_at),
+	CONSTRAINT fk_payments_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE RESTRICT ON UPDATE CASCADE
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS product_prices (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	product_id BIGINT,
+	price DOUBLE,
+	effective_at DATETIME(3) NULL,
+	applied_at DATETIME(3) NULL,
+	created_at DATETIME(3) NULL,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_product_prices_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
@@ -0,0 +1,9 @@
+CREATE TABLE users (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	username VARCHAR(191) NOT NULL,
+	password_hash VARCHAR(255) NOT NULL,
+	created_at DATETIME(3) NULL,
+	updated_at DATETIME(3) NULL,
+	PRIMARY KEY (id),
+	UNIQUE INDEX idx_users_username (username)
+);
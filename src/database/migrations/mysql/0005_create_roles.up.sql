@@ -0,0 +1,22 @@
+CREATE TABLE roles (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	name VARCHAR(191) NOT NULL,
+	permissions TEXT NOT NULL,
+	created_at DATETIME(3) NULL,
+	updated_at DATETIME(3) NULL,
+	PRIMARY KEY (id),
+	UNIQUE INDEX idx_roles_name (name)
+);
+CREATE TABLE user_roles (
+	user_id BIGINT NOT NULL,
+	role_id BIGINT NOT NULL,
+	PRIMARY KEY (user_id, role_id),
+	INDEX idx_user_roles_role_id (role_id),
+	CONSTRAINT fk_user_roles_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+	CONSTRAINT fk_user_roles_role FOREIGN KEY (role_id) REFERENCES roles (id) ON DELETE RESTRICT
+);
+INSERT INTO roles (name, permissions, created_at, updated_at) VALUES
+	('admin', '["*"]', NOW(3), NOW(3)),
+	('cashier', '["products:read","payments:read","payments:write","stream:subscribe"]', NOW(3), NOW(3)),
+	('editor', '["products:read","products:write","products:delete"]', NOW(3), NOW(3));
+INSERT INTO user_roles (user_id, role_id) SELECT users.id, roles.id FROM users, roles WHERE roles.name = 'admin';
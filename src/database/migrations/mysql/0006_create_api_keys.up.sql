@@ -0,0 +1,16 @@
+CREATE TABLE api_keys (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	user_id BIGINT NOT NULL,
+	name VARCHAR(191) NOT NULL,
+	prefix VARCHAR(32) NOT NULL,
+	hash CHAR(64) NOT NULL,
+	permissions TEXT NOT NULL,
+	expires_at DATETIME(3) NULL,
+	last_used_at DATETIME(3) NULL,
+	revoked_at DATETIME(3) NULL,
+	created_at DATETIME(3) NULL,
+	updated_at DATETIME(3) NULL,
+	PRIMARY KEY (id),
+	UNIQUE INDEX idx_api_keys_prefix (prefix),
+	CONSTRAINT fk_api_keys_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
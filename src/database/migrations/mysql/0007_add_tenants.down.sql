@@ -0,0 +1,4 @@
+ALTER TABLE users DROP COLUMN tenant_id;
+ALTER TABLE api_keys DROP COLUMN tenant_id;
+ALTER TABLE payments DROP INDEX idx_payments_tenant_id, DROP COLUMN tenant_id;
+ALTER TABLE products DROP INDEX idx_products_tenant_id, DROP COLUMN tenant_id;
@@ -0,0 +1,4 @@
+ALTER TABLE products ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default', ADD INDEX idx_products_tenant_id (tenant_id);
+ALTER TABLE payments ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default', ADD INDEX idx_payments_tenant_id (tenant_id);
+ALTER TABLE api_keys ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE users ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1,19 @@
+CREATE TABLE audit_entries (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	tenant_id VARCHAR(64) NOT NULL,
+	actor VARCHAR(191) NOT NULL,
+	user_id BIGINT NULL,
+	key_id BIGINT NULL,
+	action VARCHAR(32) NOT NULL,
+	entity VARCHAR(32) NOT NULL,
+	entity_id BIGINT NOT NULL,
+	`before` TEXT NULL,
+	`after` TEXT NULL,
+	changes TEXT NOT NULL,
+	request_id VARCHAR(128) NOT NULL,
+	ip VARCHAR(45) NOT NULL,
+	created_at DATETIME(3) NULL,
+	PRIMARY KEY (id),
+	INDEX idx_audit_entries_entity (tenant_id, entity, entity_id),
+	INDEX idx_audit_entries_request_id (request_id)
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS products (
+	id BIGSERIAL,
+	name TEXT,
+	price DECIMAL,
+	version BIGINT NOT NULL DEFAULT 1,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	deleted_at TIMESTAMPTZ,
+	PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_products_deleted_at ON products (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_products_name_search ON products USING GIN (to_tsvector('simple', name));
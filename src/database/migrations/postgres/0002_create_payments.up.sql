@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS payments (
+	id BIGSERIAL,
+	product_id BIGINT,
+	price_paid DECIMAL,
+	version BIGINT NOT NULL DEFAULT 1,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	deleted_at TIMESTAMPTZ,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_payments_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_payments_deleted_at ON payments (deleted_at);
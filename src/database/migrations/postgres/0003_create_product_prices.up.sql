@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS product_prices (
+	id BIGSERIAL,
+	product_id BIGINT,
+	price DECIMAL,
+	effective_at TIMESTAMPTZ,
+	applied_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_product_prices_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
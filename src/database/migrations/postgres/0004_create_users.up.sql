@@ -0,0 +1,9 @@
+CREATE TABLE users (
+	id BIGSERIAL,
+	username VARCHAR(191) NOT NULL,
+	password_hash VARCHAR(255) NOT NULL,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_users_username ON users (username);
@@ -0,0 +1,22 @@
+CREATE TABLE roles (
+	id BIGSERIAL,
+	name VARCHAR(191) NOT NULL,
+	permissions TEXT NOT NULL,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_roles_name ON roles (name);
+CREATE TABLE user_roles (
+	user_id BIGINT NOT NULL,
+	role_id BIGINT NOT NULL,
+	PRIMARY KEY (user_id, role_id),
+	CONSTRAINT fk_user_roles_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+	CONSTRAINT fk_user_roles_role FOREIGN KEY (role_id) REFERENCES roles (id) ON DELETE RESTRICT
+);
+CREATE INDEX idx_user_roles_role_id ON user_roles (role_id);
+INSERT INTO roles (name, permissions, created_at, updated_at) VALUES
+	('admin', '["*"]', NOW(), NOW()),
+	('cashier', '["products:read","payments:read","payments:write","stream:subscribe"]', NOW(), NOW()),
+	('editor', '["products:read","products:write","products:delete"]', NOW(), NOW());
+INSERT INTO user_roles (user_id, role_id) SELECT users.id, roles.id FROM users, roles WHERE roles.name = 'admin';
@@ -0,0 +1,16 @@
+CREATE TABLE api_keys (
+	id BIGSERIAL,
+	user_id BIGINT NOT NULL,
+	name VARCHAR(191) NOT NULL,
+	prefix VARCHAR(32) NOT NULL,
+	hash CHAR(64) NOT NULL,
+	permissions TEXT NOT NULL,
+	expires_at TIMESTAMPTZ,
+	last_used_at TIMESTAMPTZ,
+	revoked_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_api_keys_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX idx_api_keys_prefix ON api_keys (prefix);
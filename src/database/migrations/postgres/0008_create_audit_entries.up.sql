@@ -0,0 +1,19 @@
+CREATE TABLE audit_entries (
+	id BIGSERIAL,
+	tenant_id VARCHAR(64) NOT NULL,
+	actor VARCHAR(191) NOT NULL,
+	user_id BIGINT,
+	key_id BIGINT,
+	action VARCHAR(32) NOT NULL,
+	entity VARCHAR(32) NOT NULL,
+	entity_id BIGINT NOT NULL,
+	"before" TEXT,
+	"after" TEXT,
+	changes TEXT NOT NULL,
+	request_id VARCHAR(128) NOT NULL,
+	ip VARCHAR(45) NOT NULL,
+	created_at TIMESTAMPTZ,
+	PRIMARY KEY (id)
+);
+CREATE INDEX idx_audit_entries_entity ON audit_entries (tenant_id, entity, entity_id);
+CREATE INDEX idx_audit_entries_request_id ON audit_entries (request_id);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS products (
+	id INTEGER,
+	name TEXT,
+	price REAL,
+	version INTEGER NOT NULL DEFAULT 1,
+	created_at DATETIME,
+	updated_at DATETIME,
+	deleted_at DATETIME,
+	PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_products_deleted_at ON products (deleted_at);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS payments (
+	id INTEGER,
+	product_id INTEGER,
+	price_paid REAL,
+	version INTEGER NOT NULL DEFAULT 1,
+	created_at DATETIME,
+	updated_at DATETIME,
+	deleted_at DATETIME,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_payments_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_payments_deleted_at ON payments (deleted_at);
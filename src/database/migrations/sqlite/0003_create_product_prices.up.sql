@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS product_prices (
+	id INTEGER,
+	product_id INTEGER,
+	price REAL,
+	effective_at DATETIME,
+	applied_at DATETIME,
+	created_at DATETIME,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_product_prices_product FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
@@ -0,0 +1,9 @@
+CREATE TABLE users (
+	id INTEGER,
+	username TEXT NOT NULL,
+	password_hash TEXT NOT NULL,
+	created_at DATETIME,
+	updated_at DATETIME,
+	PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_users_username ON users (username);
@@ -0,0 +1,22 @@
+CREATE TABLE roles (
+	id INTEGER,
+	name TEXT NOT NULL,
+	permissions TEXT NOT NULL,
+	created_at DATETIME,
+	updated_at DATETIME,
+	PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_roles_name ON roles (name);
+CREATE TABLE user_roles (
+	user_id INTEGER NOT NULL,
+	role_id INTEGER NOT NULL,
+	PRIMARY KEY (user_id, role_id),
+	CONSTRAINT fk_user_roles_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+	CONSTRAINT fk_user_roles_role FOREIGN KEY (role_id) REFERENCES roles (id) ON DELETE RESTRICT
+);
+CREATE INDEX idx_user_roles_role_id ON user_roles (role_id);
+INSERT INTO roles (name, permissions, created_at, updated_at) VALUES
+	('admin', '["*"]', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+	('cashier', '["products:read","payments:read","payments:write","stream:subscribe"]', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+	('editor', '["products:read","products:write","products:delete"]', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+INSERT INTO user_roles (user_id, role_id) SELECT users.id, roles.id FROM users, roles WHERE roles.name = 'admin';
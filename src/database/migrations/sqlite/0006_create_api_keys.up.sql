@@ -0,0 +1,16 @@
+CREATE TABLE api_keys (
+	id INTEGER,
+	user_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	prefix TEXT NOT NULL,
+	hash TEXT NOT NULL,
+	permissions TEXT NOT NULL,
+	expires_at DATETIME,
+	last_used_at DATETIME,
+	revoked_at DATETIME,
+	created_at DATETIME,
+	updated_at DATETIME,
+	PRIMARY KEY (id),
+	CONSTRAINT fk_api_keys_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX idx_api_keys_prefix ON api_keys (prefix);
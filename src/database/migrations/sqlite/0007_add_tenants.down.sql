@@ -0,0 +1,6 @@
+ALTER TABLE users DROP COLUMN tenant_id;
+ALTER TABLE api_keys DROP COLUMN tenant_id;
+DROP INDEX idx_payments_tenant_id;
+ALTER TABLE payments DROP COLUMN tenant_id;
+DROP INDEX idx_products_tenant_id;
+ALTER TABLE products DROP COLUMN tenant_id;
@@ -0,0 +1,6 @@
+ALTER TABLE products ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX idx_products_tenant_id ON products (tenant_id);
+ALTER TABLE payments ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX idx_payments_tenant_id ON payments (tenant_id);
+ALTER TABLE api_keys ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE users ADD COLUMN tenant_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,19 @@
+CREATE TABLE audit_entries (
+	id INTEGER,
+	tenant_id TEXT NOT NULL,
+	actor TEXT NOT NULL,
+	user_id INTEGER,
+	key_id INTEGER,
+	action TEXT NOT NULL,
+	entity TEXT NOT NULL,
+	entity_id INTEGER NOT NULL,
+	"before" TEXT,
+	"after" TEXT,
+	changes TEXT NOT NULL,
+	request_id TEXT NOT NULL,
+	ip TEXT NOT NULL,
+	created_at DATETIME,
+	PRIMARY KEY (id)
+);
+CREATE INDEX idx_audit_entries_entity ON audit_entries (tenant_id, entity, entity_id);
+CREATE INDEX idx_audit_entries_request_id ON audit_entries (request_id);
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const timeoutKey = "database:timeout"
+
+// QueryTimeout bounds each query run through a database, on top of the
+// deadline of its context, registered with db.Use(database.QueryTimeout(d)).
+// Row queries are left out, their rows are read once the callbacks ran.
+type QueryTimeout time.Duration
+
+// timeout is the context a query replaced, restored when the query is done
+// since chained queries share their statement.
+type timeout struct {
+	parent context.Context
+	cancel context.CancelFunc
+}
+
+func (QueryTimeout) Name() string {
+	return "timeout"
+}
+
+func (t QueryTimeout) Initialize(db *gorm.DB) error {
+	if t <= 0 {
+		return nil
+	}
+
+	callback := db.Callback()
+	errs := []error{
+		callback.Create().Before("gorm:create").Register("timeout:before_create", t.start),
+		callback.Create().After("gorm:create").Register("timeout:after_create", stop),
+		callback.Query().Before("gorm:query").Register("timeout:before_query", t.start),
+		callback.Query().After("gorm:query").Register("timeout:after_query", stop),
+		callback.Update().Before("gorm:update").Register("timeout:before_update", t.start),
+		callback.Update().After("gorm:update").Register("timeout:after_update", stop),
+		callback.Delete().Before("gorm:delete").Register("timeout:before_delete", t.start),
+		callback.Delete().After("gorm:delete").Register("timeout:after_delete", stop),
+		callback.Raw().Before("gorm:raw").Register("timeout:before_raw", t.start),
+		callback.Raw().After("gorm:raw").Register("timeout:after_raw", stop),
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t QueryTimeout) start(db *gorm.DB) {
+	parent := db.Statement.Context
+	ctx, cancel := context.WithTimeout(parent, time.Duration(t))
+	db.Statement.Context = ctx
+	db.InstanceSet(timeoutKey, timeout{parent, cancel})
+}
+
+func stop(db *gorm.DB) {
+	value, ok := db.InstanceGet(timeoutKey)
+	if !ok {
+		return
+	}
+	t := value.(timeout)
+	t.cancel()
+	db.Statement.Context = t.parent
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+// Transactor runs functions in a transaction, which the repositories join by
+// reaching the database through Conn with the context given to fn.
+type Transactor interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type transactor struct {
+	db *gorm.DB
+}
+
+func NewTransactor(db *gorm.DB) *transactor {
+	return &transactor{db}
+}
+
+// Transaction commits what fn did if it returns nil and rolls it back
+// otherwise. Called within a transaction, it runs fn in a savepoint of it.
+func (t *transactor) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return Conn(ctx, t.db).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// Conn is db for the queries of ctx, in the transaction ctx carries if any.
+func Conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
+// memoryTransactor runs the functions as they are, for the memory driver.
+// Its repositories apply each change at once, a failing function does not
+// undo the changes it made before failing.
+type memoryTransactor struct{}
+
+func NewMemoryTransactor() memoryTransactor {
+	return memoryTransactor{}
+}
+
+func (memoryTransactor) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
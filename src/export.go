@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/src/audit"
+	"go/src/config"
+	"go/src/payment"
+	"go/src/product"
+	"go/src/tenant"
+	"io"
+	"os"
+	"sort"
+)
+
+// dump is the file written by export and read by import. Prices holds the
+// price history of the products, the pending changes included.
+type dump struct {
+	Products []product.Product      `json:"products"`
+	Prices   []product.ProductPrice `json:"prices"`
+	Payments []payment.Payment      `json:"payments"`
+}
+
+// runExport writes every product, with its price history, and payment of a
+// tenant, deleted ones included, as JSON.
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := flags.String("o", "-", "file to write, - for the standard output")
+	tenantID := flags.String("tenant", tenant.Default, "tenant to export")
+
+	cfg, err := config.LoadFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	err = setupLogging(cfg.Log)
+	if err != nil {
+		return err
+	}
+	ctx, err := tenantContext(*tenantID)
+	if err != nil {
+		return err
+	}
+
+	storage, err := openStorage(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer storage.close()
+
+	deletePolicy, err := product.ParseDeletePolicy(cfg.Products.DeletePolicy)
+	if err != nil {
+		return err
+	}
+	auditService := audit.NewService(storage.audit)
+	paymentService := payment.NewService(storage.payments, storage.tx, auditService)
+	productService := product.NewService(storage.products, storage.tx, deletePolicy, auditService, paymentService)
+
+	var content dump
+	content.Products, _, err = productService.GetAll(ctx, product.ListOptions{IncludeDeleted: true})
+	if err != nil {
+		return err
+	}
+	content.Prices, err = productService.GetAllPrices(ctx)
+	if err != nil {
+		return err
+	}
+	content.Payments, _, err = paymentService.GetAll(ctx, payment.ListOptions{IncludeDeleted: true})
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(content)
+}
+
+// runImport creates the products, their price history, and the payments of
+// an export in a tenant, which need not be the exported one. They get new
+// IDs, the payments follow their product, and the deleted ones are deleted
+// again once everything is created. The import is a single transaction,
+// nothing is left of a failed one.
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	input := flags.String("i", "-", "file to read, - for the standard input")
+	tenantID := flags.String("tenant", tenant.Default, "tenant to import into")
+
+	cfg, err := config.LoadFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	err = setupLogging(cfg.Log)
+	if err != nil {
+		return err
+	}
+	ctx, err := tenantContext(*tenantID)
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if *input != "-" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	var content dump
+	err = json.NewDecoder(r).Decode(&content)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *input, err)
+	}
+
+	storage, err := openStorage(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer storage.close()
+
+	//deleted products keep their payments, whatever the configured policy
+	auditService := audit.NewService(storage.audit)
+	paymentService := payment.NewService(storage.payments, storage.tx, auditService)
+	productService := product.NewService(storage.products, storage.tx, product.DeleteArchive, auditService, paymentService)
+
+	err = storage.tx.Transaction(ctx, func(ctx context.Context) error {
+		return importDump(ctx, content, productService, paymentService)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("imported %d products and %d payments\n", len(content.Products), len(content.Payments))
+	return nil
+}
+
+func importDump(ctx context.Context, content dump, productService product.Service, paymentService payment.Service) error {
+	sort.Slice(content.Products, func(i, j int) bool {
+		return content.Products[i].ID < content.Products[j].ID
+	})
+	sort.Slice(content.Payments, func(i, j int) bool {
+		return content.Payments[i].ID < content.Payments[j].ID
+	})
+
+	productIDs := make(map[int]int, len(content.Products))
+	for _, imported := range content.Products {
+		created, err := productService.Create(ctx, product.InputProduct{
+			Name:  imported.Name,
+			Price: imported.Price,
+		})
+		if err != nil {
+			return fmt.Errorf("product %d: %w", imported.ID, err)
+		}
+		productIDs[imported.ID] = created.ID
+	}
+
+	//exports made before the history was exported keep the one of the creation
+	prices := make(map[int][]product.ProductPrice)
+	for _, price := range content.Prices {
+		if _, ok := productIDs[price.ProductID]; !ok {
+			return fmt.Errorf("price %d: product %d is not in the export", price.ID, price.ProductID)
+		}
+		prices[price.ProductID] = append(prices[price.ProductID], price)
+	}
+	for _, imported := range content.Products {
+		history, ok := prices[imported.ID]
+		if !ok {
+			continue
+		}
+		_, err := productService.ImportPrices(ctx, productIDs[imported.ID], history)
+		if err != nil {
+			return fmt.Errorf("product %d prices: %w", imported.ID, err)
+		}
+	}
+
+	paymentIDs := make(map[int]int, len(content.Payments))
+	for _, imported := range content.Payments {
+		productID, ok := productIDs[imported.ProductID]
+		if !ok {
+			return fmt.Errorf("payment %d: product %d is not in the export", imported.ID, imported.ProductID)
+		}
+
+		created, err := paymentService.Create(ctx, payment.InputPayment{
+			ProductID: productID,
+			PricePaid: imported.PricePaid,
+		})
+		if err != nil {
+			return fmt.Errorf("payment %d: %w", imported.ID, err)
+		}
+		paymentIDs[imported.ID] = created.ID
+	}
+
+	for _, imported := range content.Payments {
+		if imported.DeletedAt.Valid {
+			err := paymentService.Delete(ctx, paymentIDs[imported.ID], 0)
+			if err != nil {
+				return fmt.Errorf("payment %d: %w", imported.ID, err)
+			}
+		}
+	}
+	for _, imported := range content.Products {
+		if imported.DeletedAt.Valid {
+			err := productService.Delete(ctx, productIDs[imported.ID], 0)
+			if err != nil {
+				return fmt.Errorf("product %d: %w", imported.ID, err)
+			}
+		}
+	}
+
+	return nil
+}
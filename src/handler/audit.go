@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"go/src/apperror"
+	"go/src/audit"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errInvalidQuery = apperror.NewBadRequest("invalid_query", "query parameters are not valid, dates are RFC 3339")
+
+type auditHandler struct {
+	responder
+	auditService audit.Service
+}
+
+func NewAuditHandler(auditService audit.Service) *auditHandler {
+	return &auditHandler{
+		responder{unified},
+		auditService,
+	}
+}
+
+func (ah *auditHandler) GetAll(c *gin.Context) {
+	meta, err := pagination(c)
+	if err != nil {
+		ah.respondError(c, err)
+		return
+	}
+
+	var options audit.ListOptions
+	err = c.ShouldBindQuery(&options)
+	if err != nil {
+		ah.respondError(c, errInvalidQuery.Wrap(err))
+		return
+	}
+	options.Page = meta.Page
+	options.PerPage = meta.PerPage
+
+	entries, total, err := ah.auditService.GetAll(c.Request.Context(), options)
+	if err != nil {
+		ah.respondError(c, err)
+		return
+	}
+
+	meta.Total = total
+	ah.respond(c, http.StatusOK, Response{
+		Success: true,
+		Data:    entries,
+		Meta:    &meta,
+	})
+}
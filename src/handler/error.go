@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"go/src/apperror"
+	"go/src/auth"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	errInvalidID   = apperror.NewBadRequest("invalid_id", "id parameter must be an integer")
+	errInvalidBody = apperror.NewBadRequest("invalid_body", "cannot extract JSON body")
+	errValidation  = apperror.NewValidation("validation_failed", "request body is not valid")
+	errInternal    = apperror.New(apperror.Internal, "internal_error", "something went wrong")
+	errTimeout     = apperror.NewUnavailable("timeout", "the request took too long, try again later")
+)
+
+var statuses = map[apperror.Kind]int{
+	apperror.Internal:             http.StatusInternalServerError,
+	apperror.BadRequest:           http.StatusBadRequest,
+	apperror.NotFound:             http.StatusNotFound,
+	apperror.Validation:           http.StatusUnprocessableEntity,
+	apperror.Conflict:             http.StatusConflict,
+	apperror.PreconditionFailed:   http.StatusPreconditionFailed,
+	apperror.UnsupportedMediaType: http.StatusUnsupportedMediaType,
+	apperror.Unauthorized:         http.StatusUnauthorized,
+	apperror.Forbidden:            http.StatusForbidden,
+	apperror.TooManyRequests:      http.StatusTooManyRequests,
+	apperror.Unavailable:          http.StatusServiceUnavailable,
+}
+
+// Problem is an RFC 7807 problem details document, Code is the stable
+// identifier clients should branch on.
+type Problem struct {
+	Type   string       `json:"type"`
+	Title  string       `json:"title"`
+	Status int          `json:"status"`
+	Detail string       `json:"detail"`
+	Code   string       `json:"code"`
+	Errors []FieldError `json:"errors,omitempty"`
+	//Permission is the one missing for a 403
+	Permission auth.Permission `json:"permission,omitempty"`
+}
+
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+}
+
+func init() {
+	//report JSON field names in validation errors
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+}
+
+// bindError classifies an error returned while binding a request body.
+func bindError(err error) error {
+	var fields validator.ValidationErrors
+	if errors.As(err, &fields) {
+		return errValidation.Wrap(err)
+	}
+	return errInvalidBody.Wrap(err)
+}
+
+// newProblem describes err as a problem document. Errors that are not domain
+// errors are attached to the context for the logger and hidden behind a
+// generic 500, or a 503 for a query that timed out.
+func newProblem(c *gin.Context, err error) Problem {
+	var appErr *apperror.Error
+	switch {
+	case errors.As(err, &appErr):
+	case errors.Is(err, context.DeadlineExceeded):
+		c.Error(err)
+		appErr = errTimeout
+	default:
+		c.Error(err)
+		appErr = errInternal
+	}
+
+	status := statuses[appErr.Kind]
+	problem := Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: appErr.Error(),
+		Code:   appErr.Code,
+	}
+
+	var fields validator.ValidationErrors
+	if errors.As(err, &fields) {
+		problem.Detail = appErr.Message
+		for _, field := range fields {
+			problem.Errors = append(problem.Errors, FieldError{
+				Field: field.Field(),
+				Rule:  field.Tag(),
+			})
+		}
+	}
+
+	var missing auth.MissingPermission
+	if errors.As(err, &missing) {
+		problem.Detail = appErr.Message
+		problem.Permission = missing.Permission
+	}
+
+	return problem
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"go/src/apperror"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errPrecondition = apperror.NewPreconditionFailed("invalid_if_match", "If-Match must be a single strong ETag or *")
+
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatch returns the version required by the If-Match header, or 0 when
+// any version is accepted.
+func ifMatch(c *gin.Context) (int, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, errPrecondition
+	}
+
+	version, err := strconv.Atoi(header[1 : len(header)-1])
+	if err != nil || version <= 0 {
+		return 0, errPrecondition
+	}
+
+	return version, nil
+}
+
+// notModified reports whether the If-None-Match header already matches the
+// given version, in which case the 304 response has been written.
+func notModified(c *gin.Context, version int) bool {
+	tag := etag(version)
+	c.Header("ETag", tag)
+
+	header := c.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkTimeout bounds each component check so that a hung dependency makes
+// the probe fail instead of hanging it.
+const checkTimeout = 2 * time.Second
+
+// HealthCheck is a component the server needs to serve traffic.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+type ComponentStatus struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type BuildInfo struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+type Status struct {
+	Status     string            `json:"status"`
+	Build      BuildInfo         `json:"build"`
+	StartedAt  time.Time         `json:"started_at"`
+	Uptime     string            `json:"uptime"`
+	Components []ComponentStatus `json:"components"`
+}
+
+type healthHandler struct {
+	draining  atomic.Bool
+	checks    []HealthCheck
+	build     BuildInfo
+	startedAt time.Time
+}
+
+// NewHealthHandler serves the probes, version is the release set at build
+// time and checks are run by the readiness probe and the status page.
+func NewHealthHandler(version string, checks ...HealthCheck) *healthHandler {
+	return &healthHandler{
+		checks:    checks,
+		build:     buildInfo(version),
+		startedAt: time.Now(),
+	}
+}
+
+func buildInfo(version string) BuildInfo {
+	build := BuildInfo{
+		Version:   version,
+		GoVersion: runtime.Version(),
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return build
+	}
+	//vcs settings are only stamped by go build, not go run
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			build.Revision = setting.Value
+		case "vcs.time":
+			build.Time = setting.Value
+		case "vcs.modified":
+			build.Modified = setting.Value == "true"
+		}
+	}
+	return build
+}
+
+// Drain makes the readiness probe fail so that the load balancer stops
+// sending traffic while the server shuts down.
+func (hh *healthHandler) Drain() {
+	hh.draining.Store(true)
+}
+
+func (hh *healthHandler) check(ctx context.Context) ([]ComponentStatus, bool) {
+	components := make([]ComponentStatus, len(hh.checks))
+	healthy := true
+
+	for i, check := range hh.checks {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		start := time.Now()
+		err := check.Check(checkCtx)
+		cancel()
+
+		components[i] = ComponentStatus{
+			Name:      check.Name,
+			Status:    "up",
+			LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if err != nil {
+			components[i].Status = "down"
+			components[i].Error = err.Error()
+			healthy = false
+		}
+	}
+
+	return components, healthy
+}
+
+// Live only tells that the process is up, it does not look at its
+// dependencies so that a database outage does not get the server restarted.
+func (hh *healthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (hh *healthHandler) Ready(c *gin.Context) {
+	if hh.draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+		return
+	}
+
+	components, healthy := hh.check(c.Request.Context())
+	if !healthy {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "components": components})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
+func (hh *healthHandler) Status(c *gin.Context) {
+	components, healthy := hh.check(c.Request.Context())
+
+	status := Status{
+		Status:     "ok",
+		Build:      hh.build,
+		StartedAt:  hh.startedAt,
+		Uptime:     time.Since(hh.startedAt).Round(time.Second).String(),
+		Components: components,
+	}
+	code := http.StatusOK
+	switch {
+	case hh.draining.Load():
+		status.Status = "draining"
+		code = http.StatusServiceUnavailable
+	case !healthy:
+		status.Status = "degraded"
+		code = http.StatusServiceUnavailable
+	}
+
+	c.JSON(code, status)
+}
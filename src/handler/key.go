@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"go/src/auth"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type keyHandler struct {
+	responder
+	authService auth.Service
+}
+
+func NewKeyHandler(authService auth.Service) *keyHandler {
+	return &keyHandler{
+		responder{unified},
+		authService,
+	}
+}
+
+func (kh *keyHandler) GetAll(c *gin.Context) {
+	keys, err := kh.authService.GetKeys(c.Request.Context())
+	if err != nil {
+		kh.respondError(c, err)
+		return
+	}
+
+	kh.respond(c, http.StatusOK, Response{
+		Success: true,
+		Data:    keys,
+	})
+}
+
+func (kh *keyHandler) Create(c *gin.Context) {
+	var input auth.InputAPIKey
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		kh.respondError(c, bindError(err))
+		return
+	}
+
+	key, err := kh.authService.CreateKey(c.Request.Context(), input)
+	if err != nil {
+		kh.respondError(c, err)
+		return
+	}
+
+	kh.respond(c, http.StatusCreated, Response{
+		Success: true,
+		Message: "New API key created, it will not be shown again",
+		Data:    key,
+	})
+}
+
+func (kh *keyHandler) Revoke(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		kh.respondError(c, errInvalidID)
+		return
+	}
+
+	key, err := kh.authService.RevokeKey(c.Request.Context(), id)
+	if err != nil {
+		kh.respondError(c, err)
+		return
+	}
+
+	kh.respond(c, http.StatusOK, Response{
+		Success: true,
+		Message: "API key revoked",
+		Data:    key,
+	})
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"errors"
+	"go/src/payment"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isLegacy tells whether the request targets v1, which keeps the envelopes,
+// payment field names and error statuses clients relied on before v2.
+func isLegacy(c *gin.Context) bool {
+	return apiVersion(c) < 2
+}
+
+// legacyError is the status and message legacy clients get for err: the
+// errors the API already had answer a 400 with the message they had, the
+// ones it gained since (authentication, permissions, rate limits, timeouts)
+// keep their status.
+func legacyError(problem Problem, err error) (int, string) {
+	switch {
+	case errors.Is(err, errInvalidID):
+		return http.StatusBadRequest, "Wrong id parameter"
+	case errors.Is(err, errInvalidBody), errors.Is(err, errValidation):
+		return http.StatusBadRequest, "Cannot extract JSON body"
+	}
+
+	switch problem.Status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return problem.Status, problem.Title
+	}
+	return http.StatusBadRequest, "Something went wrong"
+}
+
+// ProductResponse is the product envelope served to legacy clients.
+type ProductResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data"`
+}
+
+// PaymentResponse is the payment envelope served to legacy clients.
+type PaymentResponse struct {
+	Success bool
+	Message string
+	Data    interface{}
+}
+
+func legacyProduct(response Response) interface{} {
+	return ProductResponse{response.Success, response.Message, response.Data}
+}
+
+func legacyPayment(response Response) interface{} {
+	return PaymentResponse{response.Success, response.Message, response.Data}
+}
+
+type legacyInputPayment struct {
+	ProductID int     `json:"productid" binding:"required"`
+	PricePaid float64 `json:"pricepaid" binding:"required"`
+}
+
+type legacyPatchPayment struct {
+	ProductID *int     `json:"productid" binding:"omitempty,gt=0"`
+	PricePaid *float64 `json:"pricepaid" binding:"omitempty,gte=0"`
+}
+
+// bindInputPayment binds a payment body, under the legacy field names for
+// legacy clients.
+func bindInputPayment(c *gin.Context, input *payment.InputPayment) error {
+	if !isLegacy(c) {
+		return c.ShouldBindJSON(input)
+	}
+
+	var legacy legacyInputPayment
+	err := c.ShouldBindJSON(&legacy)
+	if err != nil {
+		return err
+	}
+
+	input.ProductID = legacy.ProductID
+	input.PricePaid = legacy.PricePaid
+	return nil
+}
+
+// bindPatchPayment is bindMergePatch for payments, under the legacy field
+// names for legacy clients.
+func bindPatchPayment(c *gin.Context, patch *payment.PatchPayment) error {
+	if !isLegacy(c) {
+		return bindMergePatch(c, patch)
+	}
+
+	var legacy legacyPatchPayment
+	err := bindMergePatch(c, &legacy)
+	if err != nil {
+		return err
+	}
+
+	patch.ProductID = legacy.ProductID
+	patch.PricePaid = legacy.PricePaid
+	return nil
+}
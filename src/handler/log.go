@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"fmt"
+	"go/src/auth"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/exp/slog"
+)
+
+// AccessLog logs every request once served, with its subject and the errors
+// hidden behind a 500. Server errors are logged as errors, the other
+// requests at info level.
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("ip", c.ClientIP()),
+		}
+		//the context of the request, now authenticated
+		ctx := c.Request.Context()
+		if subject, ok := auth.SubjectFrom(ctx); ok {
+			attrs = append(attrs, slog.Int("user_id", subject.UserID))
+			if subject.KeyID != 0 {
+				attrs = append(attrs, slog.Int("key_id", subject.KeyID))
+			}
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.Any("errors", c.Errors.Errors()))
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(ctx, level, "request", attrs...)
+	}
+}
+
+// Recovery turns a panic into a 500, and logs it with its stack.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err interface{}) {
+		slog.ErrorCtx(c.Request.Context(), "panic", "error", fmt.Sprint(err), "stack", string(debug.Stack()))
+		c.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/src/apperror"
+	"io"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const mergePatchContentType = "application/merge-patch+json"
+
+var errPatchContentType = apperror.New(apperror.UnsupportedMediaType, "unsupported_content_type",
+	"content type must be "+mergePatchContentType+" or application/json")
+
+// bindMergePatch decodes a JSON Merge Patch (RFC 7396) body into obj, whose
+// fields must be pointers, and validates the members that were supplied.
+// All our fields are mandatory so removing one with null is refused.
+func bindMergePatch(c *gin.Context, obj interface{}) error {
+	switch c.ContentType() {
+	case mergePatchContentType, binding.MIMEJSON:
+	default:
+		return errPatchContentType
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return errInvalidBody.Wrap(err)
+	}
+
+	var members map[string]json.RawMessage
+	err = json.Unmarshal(body, &members)
+	if err != nil {
+		return errInvalidBody.Wrap(err)
+	}
+
+	for name, value := range members {
+		if string(value) == "null" {
+			return errValidation.Wrap(fmt.Errorf("field %q cannot be removed", name))
+		}
+	}
+
+	err = json.Unmarshal(body, obj)
+	if err != nil {
+		return errInvalidBody.Wrap(err)
+	}
+
+	err = binding.Validator.ValidateStruct(obj)
+	if err != nil {
+		return bindError(err)
+	}
+
+	return nil
+}
@@ -1,122 +1,170 @@
 package handler
 
 import (
+	"go/src/auth"
 	"go/src/broadcaster"
 	"go/src/payment"
+	"go/src/product"
+	"go/src/tenant"
 	"io"
 	"net/http"
 	"strconv"
 
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
+	"golang.org/x/exp/slog"
 )
 
-type PaymentResponse struct {
-	Success bool
-	Message string
-	Data    interface{}
-}
-
 type paymentHandler struct {
+	responder
 	paymentService payment.Service
 	broadcaster    broadcaster.Broadcaster
 }
 
 func NewPaymentHandler(paymentService payment.Service, broadcaster broadcaster.Broadcaster) *paymentHandler {
 	return &paymentHandler{
+		responder{legacyPayment},
 		paymentService,
 		broadcaster,
 	}
 }
 
+// streamBuffer is the number of events a slow client may lag behind before
+// it starts missing some.
+const streamBuffer = 10
+
+// Stream sends the events of the tenant of the request only.
 func (ph *paymentHandler) Stream(c *gin.Context) {
-	listener := make(chan interface{})
-	ph.broadcaster.Register(listener)
-	defer ph.broadcaster.Unregister(listener)
+	tenantID, err := tenant.Require(c.Request.Context())
+	if err != nil {
+		ph.respondError(c, err)
+		return
+	}
+
+	listener := make(chan interface{}, streamBuffer)
+	ph.broadcaster.Register(tenantID, listener)
+	defer ph.broadcaster.Unregister(tenantID, listener)
 
 	c.Stream(func(w io.Writer) bool {
-		for {
-			select {
-			case pmt := <-listener:
-				c.SSEvent("Payment created/updated", pmt)
+		select {
+		case message, ok := <-listener:
+			//the broadcaster closes its listeners on shutdown
+			if !ok {
+				return false
+			}
+
+			event, ok := message.(broadcaster.Event)
+			if !ok {
 				return true
 			}
+
+			data := event.Data
+			if apiVersion(c) >= 2 {
+				data = presentV2(event.Data)
+			}
+
+			var name string
+			switch event.Data.(type) {
+			case payment.Payment:
+				name = "Payment created/updated"
+			case product.Product:
+				name = "Product updated"
+			default:
+				return true
+			}
+			c.Render(-1, sse.Event{Id: event.RequestID, Event: name, Data: data})
+			return true
+		case <-c.Request.Context().Done():
+			return false
 		}
 	})
 }
 
+// publish sends a changed payment to the streams of its tenant.
+func (ph *paymentHandler) publish(c *gin.Context, p payment.Payment) {
+	ctx := c.Request.Context()
+	if !ph.broadcaster.Submit(p.TenantID, broadcaster.NewEvent(ctx, p)) {
+		slog.WarnCtx(ctx, "payment not sent to the streams", "payment_id", p.ID)
+	}
+}
+
 func (ph *paymentHandler) Create(c *gin.Context) {
 	var input payment.InputPayment
-	err := c.ShouldBindJSON(&input)
+	err := bindInputPayment(c, &input)
 	if err != nil {
-		response := &PaymentResponse{
-			Success: false,
-			Message: "Cannot extract JSON body",
-			Data:    err.Error(),
-		}
-		c.JSON(http.StatusBadRequest, response)
+		ph.respondError(c, bindError(err))
 		return
 	}
 
-	newPayment, err := ph.paymentService.Create(input)
+	newPayment, err := ph.paymentService.Create(c.Request.Context(), input)
 	if err != nil {
-		response := &PaymentResponse{
-			Success: false,
-			Message: "Something went wrong",
-			Data:    err.Error(),
-		}
-		c.JSON(http.StatusBadRequest, response)
+		ph.respondError(c, err)
 		return
 	}
 
-	response := &PaymentResponse{
+	ph.respond(c, http.StatusCreated, Response{
 		Success: true,
 		Message: "New payment created",
 		Data:    newPayment,
-	}
-	c.JSON(http.StatusCreated, response)
+	})
 
 	//On envoie le payment au broadcaster
-	ph.broadcaster.Submit(newPayment)
+	ph.publish(c, newPayment)
 }
 
 func (ph *paymentHandler) GetAll(c *gin.Context) {
-	payments, err := ph.paymentService.GetAll()
+	meta, err := pagination(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, PaymentResponse{
-			Success: false,
-			Message: "Something went wrong",
-			Data:    err.Error(),
-		})
+		ph.respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, PaymentResponse{
+	//deleted payments are only listed to those who can delete and restore them
+	includeDeleted := c.Query("include_deleted") == "true"
+	if includeDeleted {
+		err = auth.Authorize(c.Request.Context(), auth.PaymentsDelete)
+		if err != nil {
+			ph.respondError(c, err)
+			return
+		}
+	}
+
+	payments, total, err := ph.paymentService.GetAll(c.Request.Context(), payment.ListOptions{
+		IncludeDeleted: includeDeleted,
+		Page:           meta.Page,
+		PerPage:        meta.PerPage,
+	})
+	if err != nil {
+		ph.respondError(c, err)
+		return
+	}
+
+	meta.Total = total
+	ph.respond(c, http.StatusOK, Response{
 		Success: true,
 		Data:    payments,
+		Meta:    &meta,
 	})
 }
 
 func (ph *paymentHandler) GetById(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, PaymentResponse{
-			Success: false,
-			Message: "Wrong id parameter",
-			Data:    err.Error(),
-		})
+		ph.respondError(c, errInvalidID)
 		return
 	}
 
-	payment, err := ph.paymentService.GetById(id)
+	payment, err := ph.paymentService.GetById(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, PaymentResponse{
-			Success: false,
-			Message: "Something went wrong",
-			Data:    err.Error(),
-		})
+		ph.respondError(c, err)
+		return
+	}
+
+	if notModified(c, payment.Version) {
 		return
 	}
-	c.JSON(http.StatusOK, PaymentResponse{
+
+	ph.respond(c, http.StatusOK, Response{
 		Success: true,
 		Data:    payment,
 	})
@@ -125,71 +173,118 @@ func (ph *paymentHandler) GetById(c *gin.Context) {
 func (ph *paymentHandler) Update(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, PaymentResponse{
-			Success: false,
-			Message: "Wrong id parameter",
-			Data:    err.Error(),
-		})
+		ph.respondError(c, errInvalidID)
+		return
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		ph.respondError(c, err)
 		return
 	}
 
 	var input payment.InputPayment
-	err = c.ShouldBindJSON(&input)
+	err = bindInputPayment(c, &input)
 	if err != nil {
-		response := &PaymentResponse{
-			Success: false,
-			Message: "Cannot extract JSON body",
-			Data:    err.Error(),
-		}
-		c.JSON(http.StatusBadRequest, response)
+		ph.respondError(c, bindError(err))
 		return
 	}
 
-	payment, err := ph.paymentService.Update(id, input)
+	updated, err := ph.paymentService.Update(c.Request.Context(), id, version, input)
 	if err != nil {
-		response := &PaymentResponse{
-			Success: false,
-			Message: "Something went wrong",
-			Data:    err.Error(),
-		}
-		c.JSON(http.StatusBadRequest, response)
+		ph.respondError(c, err)
 		return
 	}
 
-	response := &PaymentResponse{
+	c.Header("ETag", etag(updated.Version))
+	ph.respond(c, updatedStatus(c), Response{
 		Success: true,
 		Message: "Payment updated",
-		Data:    payment,
+		Data:    updated,
+	})
+
+	//On envoie le payment au broadcaster
+	ph.publish(c, updated)
+}
+
+func (ph *paymentHandler) Patch(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		ph.respondError(c, errInvalidID)
+		return
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		ph.respondError(c, err)
+		return
 	}
-	c.JSON(http.StatusCreated, response)
+
+	var patch payment.PatchPayment
+	err = bindPatchPayment(c, &patch)
+	if err != nil {
+		ph.respondError(c, err)
+		return
+	}
+
+	updated, err := ph.paymentService.Patch(c.Request.Context(), id, version, patch)
+	if err != nil {
+		ph.respondError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(updated.Version))
+	ph.respond(c, http.StatusOK, Response{
+		Success: true,
+		Message: "Payment updated",
+		Data:    updated,
+	})
 
 	//On envoie le payment au broadcaster
-	ph.broadcaster.Submit(payment)
+	ph.publish(c, updated)
 }
 
 func (ph *paymentHandler) Delete(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, PaymentResponse{
-			Success: false,
-			Message: "Wrong id parameter",
-			Data:    err.Error(),
-		})
+		ph.respondError(c, errInvalidID)
+		return
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		ph.respondError(c, err)
 		return
 	}
 
-	err = ph.paymentService.Delete(id)
+	err = ph.paymentService.Delete(c.Request.Context(), id, version)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, PaymentResponse{
-			Success: false,
-			Message: "Something went wrong",
-			Data:    err.Error(),
-		})
+		ph.respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, PaymentResponse{
+	ph.respond(c, http.StatusOK, Response{
 		Success: true,
 		Message: "Payment successfully deleted",
 	})
 }
+
+func (ph *paymentHandler) Restore(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		ph.respondError(c, errInvalidID)
+		return
+	}
+
+	payment, err := ph.paymentService.Restore(c.Request.Context(), id)
+	if err != nil {
+		ph.respondError(c, err)
+		return
+	}
+
+	ph.respond(c, http.StatusOK, Response{
+		Success: true,
+		Message: "Payment restored",
+		Data:    payment,
+	})
+}
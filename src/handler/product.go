@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"go/src/auth"
 	"go/src/product"
 	"net/http"
 	"strconv"
@@ -8,90 +9,90 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-type ProductResponse struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data"`
-}
-
 type productHandler struct {
+	responder
 	productService product.Service
 }
 
 func NewProductHandler(productService product.Service) *productHandler {
-	return &productHandler{productService}
+	return &productHandler{responder{legacyProduct}, productService}
 }
 
 func (ph *productHandler) Create(c *gin.Context) {
 	var input product.InputProduct
 	err := c.ShouldBindJSON(&input)
 	if err != nil {
-		response := ProductResponse{
-			Success: false,
-			Message: "Cannot extract JSON body",
-			Data:    err.Error(),
-		}
-		c.JSON(http.StatusBadRequest, response)
+		ph.respondError(c, bindError(err))
 		return
 	}
 
-	newProduct, err := ph.productService.Create(input)
+	newProduct, err := ph.productService.Create(c.Request.Context(), input)
 	if err != nil {
-		response := ProductResponse{
-			Success: false,
-			Message: "Something went wrong",
-			Data:    err.Error(),
-		}
-		c.JSON(http.StatusBadRequest, response)
+		ph.respondError(c, err)
 		return
 	}
 
-	response := ProductResponse{
+	ph.respond(c, http.StatusCreated, Response{
 		Success: true,
 		Message: "New product created",
 		Data:    newProduct,
-	}
-	c.JSON(http.StatusCreated, response)
+	})
 }
 
 func (ph *productHandler) GetAll(c *gin.Context) {
-	products, err := ph.productService.GetAll()
+	meta, err := pagination(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ProductResponse{
-			Success: false,
-			Message: "Something went wrong",
-			Data:    err.Error(),
-		})
+		ph.respondError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, ProductResponse{
+
+	//deleted products are only listed to those who can delete and restore them
+	includeDeleted := c.Query("include_deleted") == "true"
+	if includeDeleted {
+		err = auth.Authorize(c.Request.Context(), auth.ProductsDelete)
+		if err != nil {
+			ph.respondError(c, err)
+			return
+		}
+	}
+
+	products, total, err := ph.productService.GetAll(c.Request.Context(), product.ListOptions{
+		IncludeDeleted: includeDeleted,
+		Query:          c.Query("q"),
+		Page:           meta.Page,
+		PerPage:        meta.PerPage,
+	})
+	if err != nil {
+		ph.respondError(c, err)
+		return
+	}
+
+	meta.Total = total
+	ph.respond(c, http.StatusOK, Response{
 		Success: true,
 		Data:    products,
+		Meta:    &meta,
 	})
 }
 
 func (ph *productHandler) GetByID(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ProductResponse{
-			Success: false,
-			Message: "Wrong id parameter",
-			Data:    err.Error(),
-		})
+		ph.respondError(c, errInvalidID)
 		return
 	}
 
-	product, err := ph.productService.GetById(id)
+	product, err := ph.productService.GetById(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ProductResponse{
-			Success: false,
-			Message: "Something went wrong",
-			Data:    err.Error(),
-		})
+		ph.respondError(c, err)
+		return
+	}
+
+	if notModified(c, product.Version) {
 		return
 	}
 
-	c.JSON(http.StatusOK, ProductResponse{
+	ph.respond(c, http.StatusOK, Response{
 		Success: true,
 		Data:    product,
 	})
@@ -102,67 +103,165 @@ func (ph *productHandler) Update(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ProductResponse{
-			Success: false,
-			Message: "Wrong id parameter",
-			Data:    err.Error(),
-		})
+		ph.respondError(c, errInvalidID)
+		return
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		ph.respondError(c, err)
 		return
 	}
 
 	var input product.InputProduct
 	err = c.ShouldBindJSON(&input)
 	if err != nil {
-		response := ProductResponse{
-			Success: false,
-			Message: "Cannot extract JSON body",
-			Data:    err.Error(),
-		}
-		c.JSON(http.StatusBadRequest, response)
+		ph.respondError(c, bindError(err))
 		return
 	}
 
-	product, err := ph.productService.Update(id, input)
+	updated, err := ph.productService.Update(c.Request.Context(), id, version, input)
 	if err != nil {
-		response := ProductResponse{
-			Success: false,
-			Message: "Something went wrong",
-			Data:    err.Error(),
-		}
-		c.JSON(http.StatusBadRequest, response)
+		ph.respondError(c, err)
 		return
 	}
 
-	response := ProductResponse{
+	c.Header("ETag", etag(updated.Version))
+	ph.respond(c, updatedStatus(c), Response{
 		Success: true,
 		Message: "Product updated",
-		Data:    product,
+		Data:    updated,
+	})
+}
+
+func (ph *productHandler) Patch(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		ph.respondError(c, errInvalidID)
+		return
 	}
-	c.JSON(http.StatusCreated, response)
+
+	version, err := ifMatch(c)
+	if err != nil {
+		ph.respondError(c, err)
+		return
+	}
+
+	var patch product.PatchProduct
+	err = bindMergePatch(c, &patch)
+	if err != nil {
+		ph.respondError(c, err)
+		return
+	}
+
+	updated, err := ph.productService.Patch(c.Request.Context(), id, version, patch)
+	if err != nil {
+		ph.respondError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(updated.Version))
+	ph.respond(c, http.StatusOK, Response{
+		Success: true,
+		Message: "Product updated",
+		Data:    updated,
+	})
 }
 
 func (ph *productHandler) Delete(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusNotFound, ProductResponse{
-			Success: false,
+	if err != nil && isLegacy(c) {
+		//legacy clients got a 404 for an invalid id here
+		c.AbortWithStatusJSON(http.StatusNotFound, legacyProduct(Response{
 			Message: "Product not found",
-			Data:    err.Error(),
-		})
+			Data:    errInvalidID.Message,
+		}))
+		return
+	}
+	if err != nil {
+		ph.respondError(c, errInvalidID)
 		return
 	}
 
-	err = ph.productService.Delete(id)
+	version, err := ifMatch(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ProductResponse{
-			Success: false,
-			Message: "Something went wrong",
-			Data:    err.Error(),
-		})
+		ph.respondError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, ProductResponse{
+
+	err = ph.productService.Delete(c.Request.Context(), id, version)
+	if err != nil {
+		ph.respondError(c, err)
+		return
+	}
+	ph.respond(c, http.StatusOK, Response{
 		Success: true,
 		Message: "Product deleted",
 	})
 }
+
+func (ph *productHandler) GetPrices(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		ph.respondError(c, errInvalidID)
+		return
+	}
+
+	prices, err := ph.productService.GetPrices(c.Request.Context(), id)
+	if err != nil {
+		ph.respondError(c, err)
+		return
+	}
+
+	ph.respond(c, http.StatusOK, Response{
+		Success: true,
+		Data:    prices,
+	})
+}
+
+func (ph *productHandler) SchedulePrice(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		ph.respondError(c, errInvalidID)
+		return
+	}
+
+	var input product.InputPrice
+	err = c.ShouldBindJSON(&input)
+	if err != nil {
+		ph.respondError(c, bindError(err))
+		return
+	}
+
+	price, err := ph.productService.SchedulePrice(c.Request.Context(), id, input)
+	if err != nil {
+		ph.respondError(c, err)
+		return
+	}
+
+	ph.respond(c, http.StatusCreated, Response{
+		Success: true,
+		Message: "Price change scheduled",
+		Data:    price,
+	})
+}
+
+func (ph *productHandler) Restore(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		ph.respondError(c, errInvalidID)
+		return
+	}
+
+	product, err := ph.productService.Restore(c.Request.Context(), id)
+	if err != nil {
+		ph.respondError(c, err)
+		return
+	}
+
+	ph.respond(c, http.StatusOK, Response{
+		Success: true,
+		Message: "Product restored",
+		Data:    product,
+	})
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"fmt"
+	"go/src/apperror"
+	"go/src/auth"
+	"go/src/ratelimit"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errRateLimited    = apperror.NewTooManyRequests("rate_limited", "too many requests, retry later")
+	errTooManyStreams = apperror.NewTooManyRequests("too_many_streams", "too many streams open at once")
+)
+
+// client is who a limit applies to: the API key or the user of the
+// request, or its IP when it is not authenticated (yet).
+func client(c *gin.Context) string {
+	subject, ok := auth.SubjectFrom(c.Request.Context())
+	switch {
+	case ok && subject.KeyID != 0:
+		return fmt.Sprintf("key:%d", subject.KeyID)
+	case ok:
+		return fmt.Sprintf("user:%d", subject.UserID)
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// RateLimit refuses with a 429 the requests of the clients over limit. The
+// routes it is used on share the limit, each client has its own bucket. The
+// state of the bucket is sent in the RateLimit-* headers.
+func RateLimit(limit ratelimit.Limit) gin.HandlerFunc {
+	if limit.Requests == 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	limiter := ratelimit.NewLimiter(limit)
+	r := responder{unified}
+
+	return func(c *gin.Context) {
+		result := limiter.Allow(client(c))
+
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", ceilSeconds(result.Reset))
+		if !result.Allowed {
+			c.Header("Retry-After", ceilSeconds(result.RetryAfter))
+			r.respondError(c, errRateLimited)
+			return
+		}
+		c.Next()
+	}
+}
+
+// LimitConnections refuses with a 429 the requests of the clients already
+// holding max of them open, for the streams.
+func LimitConnections(max int) gin.HandlerFunc {
+	connections := ratelimit.NewConnections(max)
+	r := responder{unified}
+
+	return func(c *gin.Context) {
+		key := client(c)
+		if !connections.Acquire(key) {
+			r.respondError(c, errTooManyStreams)
+			return
+		}
+		defer connections.Release(key)
+
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"go/src/request"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestIDPattern is what a client may send as X-Request-ID, other values
+// are replaced.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestInfo puts the ID and the client IP of the request in its context.
+// The ID is the X-Request-ID sent by the client, or a new one, and is sent
+// back in the same header. The IP is only read from X-Forwarded-For behind
+// the trusted proxies of the engine.
+func RequestInfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-ID")
+		if !requestIDPattern.MatchString(id) {
+			id = request.NewID()
+		}
+		c.Header("X-Request-ID", id)
+
+		ctx := request.WithInfo(c.Request.Context(), request.Info{ID: id, IP: c.ClientIP()})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"go/src/apperror"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxPerPage = 100
+
+var errInvalidPage = apperror.NewBadRequest("invalid_pagination", "page and per_page must be positive integers, per_page at most 100")
+
+// Response is the envelope of every successful response.
+type Response struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message,omitempty"`
+	Data    interface{} `json:"data"`
+	Meta    *Meta       `json:"meta,omitempty"`
+}
+
+// Meta describes the page of a listing. Page and PerPage are left out when
+// the whole listing was requested.
+type Meta struct {
+	Page    int   `json:"page,omitempty"`
+	PerPage int   `json:"per_page,omitempty"`
+	Total   int64 `json:"total"`
+}
+
+// responder writes responses in the shape the client asked for, legacy
+// converts the envelope to the one served before it was unified.
+type responder struct {
+	legacy func(response Response) interface{}
+}
+
+func (r responder) respond(c *gin.Context, status int, response Response) {
+	if isLegacy(c) {
+		c.JSON(status, r.legacy(response))
+		return
+	}
+
+	if apiVersion(c) >= 2 {
+		response.Data = presentV2(response.Data)
+	}
+	c.JSON(status, response)
+}
+
+func (r responder) respondError(c *gin.Context, err error) {
+	problem := newProblem(c, err)
+	if isLegacy(c) {
+		status, message := legacyError(problem, err)
+		c.AbortWithStatusJSON(status, r.legacy(Response{
+			Success: false,
+			Message: message,
+			Data:    problem.Detail,
+		}))
+		return
+	}
+
+	c.Header("Content-Type", "application/problem+json")
+	c.AbortWithStatusJSON(problem.Status, problem)
+}
+
+// pagination reads the page and per_page query parameters. Without per_page
+// the whole listing is returned.
+func pagination(c *gin.Context) (Meta, error) {
+	meta := Meta{Page: 1}
+	var err error
+
+	if page := c.Query("page"); page != "" {
+		meta.Page, err = strconv.Atoi(page)
+		if err != nil || meta.Page < 1 {
+			return meta, errInvalidPage
+		}
+	}
+
+	if perPage := c.Query("per_page"); perPage != "" {
+		meta.PerPage, err = strconv.Atoi(perPage)
+		if err != nil || meta.PerPage < 1 || meta.PerPage > maxPerPage {
+			return meta, errInvalidPage
+		}
+	}
+
+	if meta.PerPage == 0 {
+		meta.Page = 0
+	}
+
+	return meta, nil
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"go/src/auth"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type roleHandler struct {
+	responder
+	authService auth.Service
+}
+
+func NewRoleHandler(authService auth.Service) *roleHandler {
+	return &roleHandler{
+		responder{unified},
+		authService,
+	}
+}
+
+func (rh *roleHandler) GetAll(c *gin.Context) {
+	roles, err := rh.authService.GetRoles(c.Request.Context())
+	if err != nil {
+		rh.respondError(c, err)
+		return
+	}
+
+	rh.respond(c, http.StatusOK, Response{
+		Success: true,
+		Data:    roles,
+	})
+}
+
+func (rh *roleHandler) Create(c *gin.Context) {
+	var input auth.InputRole
+	err := c.ShouldBindJSON(&input)
+	if err != nil {
+		rh.respondError(c, bindError(err))
+		return
+	}
+
+	role, err := rh.authService.CreateRole(c.Request.Context(), input)
+	if err != nil {
+		rh.respondError(c, err)
+		return
+	}
+
+	rh.respond(c, http.StatusCreated, Response{
+		Success: true,
+		Message: "New role created",
+		Data:    role,
+	})
+}
+
+func (rh *roleHandler) Update(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		rh.respondError(c, errInvalidID)
+		return
+	}
+
+	var input auth.InputRole
+	err = c.ShouldBindJSON(&input)
+	if err != nil {
+		rh.respondError(c, bindError(err))
+		return
+	}
+
+	role, err := rh.authService.UpdateRole(c.Request.Context(), id, input)
+	if err != nil {
+		rh.respondError(c, err)
+		return
+	}
+
+	rh.respond(c, http.StatusOK, Response{
+		Success: true,
+		Message: "Role updated",
+		Data:    role,
+	})
+}
+
+func (rh *roleHandler) Delete(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		rh.respondError(c, errInvalidID)
+		return
+	}
+
+	err = rh.authService.DeleteRole(c.Request.Context(), id)
+	if err != nil {
+		rh.respondError(c, err)
+		return
+	}
+
+	rh.respond(c, http.StatusOK, Response{
+		Success: true,
+		Message: "Role deleted",
+	})
+}
+
+func (rh *roleHandler) GetUsers(c *gin.Context) {
+	users, err := rh.authService.GetUsers(c.Request.Context())
+	if err != nil {
+		rh.respondError(c, err)
+		return
+	}
+
+	rh.respond(c, http.StatusOK, Response{
+		Success: true,
+		Data:    users,
+	})
+}
+
+func (rh *roleHandler) SetUserRoles(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		rh.respondError(c, errInvalidID)
+		return
+	}
+
+	var input auth.InputUserRoles
+	err = c.ShouldBindJSON(&input)
+	if err != nil {
+		rh.respondError(c, bindError(err))
+		return
+	}
+
+	user, err := rh.authService.SetUserRoles(c.Request.Context(), id, input)
+	if err != nil {
+		rh.respondError(c, err)
+		return
+	}
+
+	rh.respond(c, http.StatusOK, Response{
+		Success: true,
+		Message: "User roles updated",
+		Data:    user,
+	})
+}
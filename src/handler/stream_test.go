@@ -0,0 +1,190 @@
+package handler_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"go/src/audit"
+	"go/src/broadcaster"
+	"go/src/handler"
+	"go/src/internal/storagetest"
+	"go/src/payment"
+	"go/src/product"
+	"go/src/tenant"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestStreamTenants checks that the stream of a tenant only gets the
+// payments of that tenant.
+func TestStreamTenants(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, backend := range storagetest.Backends {
+		backend := backend
+		t.Run(backend.Name, func(t *testing.T) {
+			s := backend.Open(t)
+			payments := payment.NewService(s.Payments, s.Tx, audit.NewService(s.Audit))
+			bc := registrations{broadcaster.NewBroadcaster(10), make(chan string)}
+			defer bc.Close()
+
+			paymentHandler := handler.NewPaymentHandler(payments, bc)
+			router := gin.New()
+			//the tenant of the request, as authenticate resolves it
+			router.Use(handler.Version(2), func(c *gin.Context) {
+				ctx := tenant.WithID(c.Request.Context(), c.GetHeader("X-Tenant-ID"))
+				c.Request = c.Request.WithContext(ctx)
+			})
+			router.POST("/payments/", paymentHandler.Create)
+			router.GET("/payments/stream", paymentHandler.Stream)
+			server := httptest.NewServer(router)
+			defer server.Close()
+
+			tenants := []string{"acme", "globex"}
+			productIDs := make(map[string]int)
+			for _, id := range tenants {
+				p, err := s.Products.Create(tenant.WithID(context.Background(), id), product.Product{Name: "chair", Price: 10})
+				if err != nil {
+					t.Fatal(err)
+				}
+				productIDs[id] = p.ID
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			received := make(map[string]chan int)
+			for _, id := range tenants {
+				received[id] = make(chan int, 1)
+				go firstEvent(t, ctx, server.URL, id, received[id])
+			}
+			for range tenants {
+				select {
+				case topic := <-bc.topics:
+					if topic != "acme" && topic != "globex" {
+						t.Errorf("stream registered on %q, want the tenant of the request", topic)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatal("streams not registered")
+				}
+			}
+
+			//acme pays first, its payment would reach globex first if it leaked
+			created := make(map[string]int)
+			for _, id := range tenants {
+				created[id] = createPayment(t, server.URL, id, productIDs[id])
+			}
+
+			for _, id := range tenants {
+				select {
+				case paymentID := <-received[id]:
+					if paymentID != created[id] {
+						t.Errorf("stream of %s got payment %d, want its payment %d", id, paymentID, created[id])
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("stream of %s got no payment", id)
+				}
+			}
+
+			//globex can not pay for a product of acme
+			status := postPayment(t, server.URL, "globex", productIDs["acme"], nil)
+			if status != http.StatusUnprocessableEntity {
+				t.Errorf("payment of globex for a product of acme: got status %d, want %d", status, http.StatusUnprocessableEntity)
+			}
+		})
+	}
+}
+
+// firstEvent sends the ID of the first payment the stream of tenantID gets.
+func firstEvent(t *testing.T, ctx context.Context, url string, tenantID string, received chan<- int) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/payments/stream", nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	request.Header.Set("X-Tenant-ID", tenantID)
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		//the test ended before an event came
+		return
+	}
+	defer response.Body.Close()
+
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		var event struct {
+			ID int `json:"id"`
+		}
+		err = json.Unmarshal([]byte(data), &event)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		received <- event.ID
+		return
+	}
+}
+
+// registrations tells the topics the streams register on, once they are
+// registered.
+type registrations struct {
+	broadcaster.Broadcaster
+	topics chan string
+}
+
+func (r registrations) Register(topic string, listener chan<- interface{}) {
+	r.Broadcaster.Register(topic, listener)
+	r.topics <- topic
+}
+
+func createPayment(t *testing.T, url string, tenantID string, productID int) int {
+	t.Helper()
+	var response struct {
+		Data struct {
+			ID int `json:"id"`
+		} `json:"data"`
+	}
+	status := postPayment(t, url, tenantID, productID, &response)
+	if status != http.StatusCreated {
+		t.Fatalf("payment of %s: got status %d, want %d", tenantID, status, http.StatusCreated)
+	}
+	return response.Data.ID
+}
+
+func postPayment(t *testing.T, url string, tenantID string, productID int, body interface{}) int {
+	t.Helper()
+	input, err := json.Marshal(payment.InputPayment{ProductID: productID, PricePaid: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, err := http.NewRequest(http.MethodPost, url+"/payments/", strings.NewReader(string(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Tenant-ID", tenantID)
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if body != nil {
+		err = json.NewDecoder(response.Body).Decode(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return response.StatusCode
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"go/src/payment"
+	"go/src/product"
+	"strconv"
+)
+
+// money is rendered by v2 as a decimal string so that amounts survive
+// clients parsing JSON numbers as binary floats.
+type money float64
+
+func (m money) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + strconv.FormatFloat(float64(m), 'f', 2, 64) + `"`), nil
+}
+
+type productV2 struct {
+	product.Product
+	Price money `json:"price"`
+}
+
+type priceV2 struct {
+	product.ProductPrice
+	Price money `json:"price"`
+}
+
+type paymentV2 struct {
+	payment.Payment
+	Product   *productV2 `json:"product"`
+	PricePaid money      `json:"price_paid"`
+}
+
+func newProductV2(p product.Product) productV2 {
+	return productV2{p, money(p.Price)}
+}
+
+func newPaymentV2(p payment.Payment) paymentV2 {
+	v2 := paymentV2{Payment: p, PricePaid: money(p.PricePaid)}
+	if p.Product != nil {
+		product := newProductV2(*p.Product)
+		v2.Product = &product
+	}
+	return v2
+}
+
+// presentV2 converts the entities served as response data to their v2
+// representation.
+func presentV2(data interface{}) interface{} {
+	switch data := data.(type) {
+	case product.Product:
+		return newProductV2(data)
+	case []product.Product:
+		products := make([]productV2, len(data))
+		for i, p := range data {
+			products[i] = newProductV2(p)
+		}
+		return products
+	case product.ProductPrice:
+		return priceV2{data, money(data.Price)}
+	case []product.ProductPrice:
+		prices := make([]priceV2, len(data))
+		for i, p := range data {
+			prices[i] = priceV2{p, money(p.Price)}
+		}
+		return prices
+	case payment.Payment:
+		return newPaymentV2(data)
+	case []payment.Payment:
+		payments := make([]paymentV2, len(data))
+		for i, p := range data {
+			payments[i] = newPaymentV2(p)
+		}
+		return payments
+	}
+
+	return data
+}
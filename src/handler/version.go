@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const versionKey = "api_version"
+
+// Version tags the requests of a route group with the API version they
+// target. v1 keeps the contract of the unversioned /api and is deprecated in
+// favour of v2.
+func Version(version int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(versionKey, version)
+		if version < 2 {
+			c.Header("Deprecation", "true")
+			c.Header("Link", `</api/v2>; rel="successor-version"`)
+		}
+		c.Next()
+	}
+}
+
+func apiVersion(c *gin.Context) int {
+	version, ok := c.Get(versionKey)
+	if !ok {
+		return 1
+	}
+	return version.(int)
+}
+
+// updatedStatus is the status of a successful PUT, v1 answers 201 Created.
+func updatedStatus(c *gin.Context) int {
+	if apiVersion(c) < 2 {
+		return http.StatusCreated
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,40 @@
+// Package storagetest opens the repositories of every storage driver, for
+// the suites that must pass against each of them.
+package storagetest
+
+import (
+	"go/src/audit"
+	"go/src/database"
+	"go/src/database/databasetest"
+	"go/src/payment"
+	"go/src/product"
+	"testing"
+)
+
+// Stores are the repositories of one backend, sharing its database, and
+// the transactions they join.
+type Stores struct {
+	Products product.Repository
+	Payments payment.Repository
+	Audit    audit.Repository
+	Tx       database.Transactor
+}
+
+// Backend opens empty stores of one driver.
+type Backend struct {
+	Name string
+	Open func(t testing.TB) Stores
+}
+
+// Backends are the drivers the repositories are implemented for: a new
+// implementation is added here to run every suite against it.
+var Backends = []Backend{
+	{"memory", func(t testing.TB) Stores {
+		products := product.NewMemoryRepository()
+		return Stores{products, payment.NewMemoryRepository(products), audit.NewMemoryRepository(), database.NewMemoryTransactor()}
+	}},
+	{"sqlite", func(t testing.TB) Stores {
+		db := databasetest.Open(t)
+		return Stores{product.NewRepository(db), payment.NewRepository(db), audit.NewRepository(db), database.NewTransactor(db)}
+	}},
+}
@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// slowQuery is how long a query may take before it is logged as a warning.
+const slowQuery = 200 * time.Millisecond
+
+// GORM logs the queries of GORM to l: failed ones as errors, slow ones as
+// warnings and the others at debug level.
+func GORM(l *slog.Logger) logger.Interface {
+	return gormLogger{l, logger.Info}
+}
+
+type gormLogger struct {
+	logger *slog.Logger
+	level  logger.LogLevel
+}
+
+func (g gormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	g.level = level
+	return g
+}
+
+func (g gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if g.level >= logger.Info {
+		g.logger.InfoCtx(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (g gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if g.level >= logger.Warn {
+		g.logger.WarnCtx(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (g gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if g.level >= logger.Error {
+		g.logger.ErrorCtx(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (g gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if g.level == logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	level := slog.LevelDebug
+	msg := "query"
+	switch {
+	//a missing record is an answer, the caller decides whether it is an error
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		level, msg = slog.LevelError, "query failed"
+	case elapsed > slowQuery:
+		level, msg = slog.LevelWarn, "slow query"
+	}
+	if !g.logger.Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Int64("rows", rows),
+		slog.Duration("duration", elapsed),
+	}
+	if level == slog.LevelError {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	g.logger.LogAttrs(ctx, level, msg, attrs...)
+}
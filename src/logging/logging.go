@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"go/src/request"
+	"go/src/tenant"
+	"io"
+
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/slog"
+)
+
+// New returns a logger writing records of level and above to w, as JSON
+// objects or as key=value text lines. Records logged with a context carry
+// the ID of its request, its tenant and its trace.
+func New(w io.Writer, level string, format string) (*slog.Logger, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(level))
+	if err != nil {
+		return nil, fmt.Errorf("log level: %w", err)
+	}
+
+	options := slog.HandlerOptions{Level: l}
+	var h slog.Handler
+	switch format {
+	case "json":
+		h = options.NewJSONHandler(w)
+	case "text":
+		h = options.NewTextHandler(w)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+
+	return slog.New(contextHandler{h}), nil
+}
+
+// contextHandler adds what the context of a record tells about it.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if info, ok := request.FromContext(ctx); ok {
+		r.AddAttrs(slog.String("request_id", info.ID))
+	}
+	if id, ok := tenant.FromContext(ctx); ok {
+		r.AddAttrs(slog.String("tenant", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		r.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -1,62 +1,340 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"go/src/audit"
+	"go/src/auth"
 	"go/src/broadcaster"
+	"go/src/config"
 	"go/src/handler"
+	"go/src/logging"
+	"go/src/metrics"
 	"go/src/payment"
 	"go/src/product"
-	"log"
+	"go/src/ratelimit"
+	"go/src/tenant"
+	"go/src/tracing"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/driver/mysql"
-	"gorm.io/gorm"
+	"golang.org/x/exp/slog"
 )
 
+// version is the release of the binary, set with
+// go build -ldflags "-X main.version=1.2.0"
+var version = "dev"
+
+// commands are the subcommands of the binary, serve runs when none is given.
+var commands = map[string]func(args []string) error{
+	"serve":   runServe,
+	"migrate": runMigrate,
+	"seed":    runSeed,
+	"export":  runExport,
+	"import":  runImport,
+	"reindex": runReindex,
+	"user":    runUser,
+}
+
 func main() {
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	run, ok := commands[command]
+	if !ok {
+		slog.Error("unknown command, expected serve, migrate, seed, export, import, reindex or user", "command", command)
+		os.Exit(1)
+	}
 
-	dbURL := "user:password@tcp(127.0.0.1:3309)/goapi?charset=utf8mb4&parseTime=True&loc=Local"
+	err := run(args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		slog.Error("command failed", "command", command, "error", err.Error())
+		os.Exit(1)
+	}
+}
 
-	db, err := gorm.Open(mysql.Open(dbURL), &gorm.Config{})
+// setupLogging makes the logger of cfg the default one, which the log
+// package writes to as well.
+func setupLogging(cfg config.Log) error {
+	logger, err := logging.New(os.Stderr, cfg.Level, cfg.Format)
 	if err != nil {
-		log.Fatal(err.Error())
+		return err
 	}
 
-	db.AutoMigrate(&payment.Payment{}, &product.Product{})
+	slog.SetDefault(logger)
+	return nil
+}
 
-	broadcaster := broadcaster.NewBroadcaster(10)
+// tenantContext is the context of the commands working on the catalog of
+// the tenant id.
+func tenantContext(id string) (context.Context, error) {
+	if !tenant.Valid(id) {
+		return nil, tenant.ErrInvalid
+	}
+	return tenant.WithID(context.Background(), id), nil
+}
 
-	productRepository := product.NewRepository(db)
-	productService := product.NewService(productRepository)
-	productHandler := handler.NewProductHandler(productService)
+// runServe serves the API until SIGINT or SIGTERM, then shuts down
+// gracefully.
+func runServe(args []string) error {
+	cfg, err := config.Load(args)
+	if err != nil {
+		return err
+	}
+	err = setupLogging(cfg.Log)
+	if err != nil {
+		return err
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, version)
+	if err != nil {
+		return err
+	}
+
+	storage, err := openStorage(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer storage.close()
+
+	broadcaster := broadcaster.NewBroadcaster(cfg.Broadcaster.Buffer)
 
-	paymentRepository := payment.NewRepository(db)
-	paymentService := payment.NewService(paymentRepository)
+	deletePolicy, err := product.ParseDeletePolicy(cfg.Products.DeletePolicy)
+	if err != nil {
+		return err
+	}
+
+	auditService := audit.NewService(storage.audit)
+	auditHandler := handler.NewAuditHandler(auditService)
+
+	payments := payment.NewService(storage.payments, storage.tx, auditService)
+	paymentService := payment.NewTracedService(payments)
 	paymentHandler := handler.NewPaymentHandler(paymentService, broadcaster)
 
-	r := gin.Default()
-	api := r.Group("/api")
-	{
-		products := api.Group("/products")
+	productService := product.NewTracedService(product.NewService(storage.products, storage.tx, deletePolicy, auditService, payments))
+	productHandler := handler.NewProductHandler(productService)
+
+	priceScheduler := product.NewScheduler(productService, broadcaster, cfg.Products.PriceInterval)
+	//for the errors below, the shutdown stops it before flushing the spans
+	defer priceScheduler.Stop()
+
+	secret := []byte(cfg.Auth.Secret)
+	if len(secret) == 0 {
+		slog.Warn("auth.secret is not set, tokens will not survive a restart")
+		secret = make([]byte, 32)
+		_, err = rand.Read(secret)
+		if err != nil {
+			return err
+		}
+	}
+
+	authService := auth.NewService(storage.users, secret, cfg.Auth.AccessTTL, cfg.Auth.RefreshTTL)
+	authHandler := handler.NewAuthHandler(authService)
+	roleHandler := handler.NewRoleHandler(authService)
+	keyHandler := handler.NewKeyHandler(authService)
+	authenticate := handler.Authenticate(authService)
+	can := handler.Require
+
+	if cfg.Auth.AdminPassword != "" {
+		//bound to no tenant, the admin works on any
+		_, err = authService.CreateUser(context.Background(), auth.InputUser{
+			Username: cfg.Auth.AdminUsername,
+			Password: cfg.Auth.AdminPassword,
+			Roles:    []string{auth.AdminRole},
+		})
+		if err != nil && !errors.Is(err, auth.ErrUserExists) {
+			return err
+		}
+	}
+
+	healthHandler := handler.NewHealthHandler(version, append(storage.checks,
+		handler.HealthCheck{Name: "broadcaster", Check: func(ctx context.Context) error {
+			if !broadcaster.Running() {
+				return errors.New("broadcaster is closed")
+			}
+			return nil
+		}},
+	)...)
+
+	//the limiters are shared by the versions of the routes
+	limitAuth := handler.RateLimit(ratelimit.Limit(cfg.RateLimit.Auth))
+	//before authenticate, invalid credentials are limited by IP too
+	limitCredentials := handler.RateLimit(ratelimit.Limit(cfg.RateLimit.Credentials))
+	limitProducts := handler.RateLimit(ratelimit.Limit(cfg.RateLimit.Products))
+	limitPayments := handler.RateLimit(ratelimit.Limit(cfg.RateLimit.Payments))
+	limitAdmin := handler.RateLimit(ratelimit.Limit(cfg.RateLimit.Admin))
+	limitStreams := handler.LimitConnections(cfg.RateLimit.StreamConnections)
+
+	err = metrics.RegisterBroadcaster(broadcaster)
+	if err != nil {
+		return err
+	}
+
+	//the access log replaces the one of gin.Default, and carries the request ID
+	r := gin.New()
+	//X-Forwarded-For can be sent by anyone, it only tells the client IP
+	//behind the proxies of the configuration
+	err = r.SetTrustedProxies(cfg.HTTP.TrustedProxies)
+	if err != nil {
+		return err
+	}
+	r.Use(handler.RequestInfo(), handler.AccessLog(), tracing.HTTP(), metrics.HTTP(), handler.Recovery())
+	r.GET("/healthz", healthHandler.Live)
+	r.GET("/readyz", healthHandler.Ready)
+	r.GET("/status", healthHandler.Status)
+
+	routes := func(api *gin.RouterGroup) {
+		tokens := api.Group("/auth", limitAuth)
 		{
-			products.POST("/", productHandler.Create)
+			tokens.POST("/login", authHandler.Login)
+			tokens.POST("/refresh", authHandler.Refresh)
+		}
+		products := api.Group("/products", limitCredentials, authenticate, limitProducts, can(auth.ProductsRead))
+		{
+			products.POST("/", can(auth.ProductsWrite), productHandler.Create)
 			products.GET("/", productHandler.GetAll)
 			products.GET("/:id", productHandler.GetByID)
-			products.PUT("/:id", productHandler.Update)
-			products.DELETE("/:id", productHandler.Delete)
+			products.PUT("/:id", can(auth.ProductsWrite), productHandler.Update)
+			products.PATCH("/:id", can(auth.ProductsWrite), productHandler.Patch)
+			products.DELETE("/:id", can(auth.ProductsDelete), productHandler.Delete)
+			products.POST("/:id/restore", can(auth.ProductsDelete), productHandler.Restore)
+			products.GET("/:id/prices", productHandler.GetPrices)
+			products.POST("/:id/prices", can(auth.ProductsWrite), productHandler.SchedulePrice)
+		}
+		//payments embed their product, reading them is enough to see it
+		payments := api.Group("/payments", limitCredentials, authenticate, limitPayments)
+		{
+			payments.POST("/", can(auth.PaymentsWrite), paymentHandler.Create)
+			payments.GET("/", can(auth.PaymentsRead), paymentHandler.GetAll)
+			payments.GET("/stream", can(auth.StreamSubscribe), limitStreams, paymentHandler.Stream)
+			payments.GET("/:id", can(auth.PaymentsRead), paymentHandler.GetById)
+			payments.PUT("/:id", can(auth.PaymentsWrite), paymentHandler.Update)
+			payments.PATCH("/:id", can(auth.PaymentsWrite), paymentHandler.Patch)
+			payments.DELETE("/:id", can(auth.PaymentsDelete), paymentHandler.Delete)
+			payments.POST("/:id/restore", can(auth.PaymentsDelete), paymentHandler.Restore)
+		}
+		roles := api.Group("/roles", limitCredentials, authenticate, limitAdmin, can(auth.RolesManage))
+		{
+			roles.GET("/", roleHandler.GetAll)
+			roles.POST("/", roleHandler.Create)
+			roles.PUT("/:id", roleHandler.Update)
+			roles.DELETE("/:id", roleHandler.Delete)
+		}
+		users := api.Group("/users", limitCredentials, authenticate, limitAdmin, can(auth.RolesManage))
+		{
+			users.GET("/", roleHandler.GetUsers)
+			users.PUT("/:id/roles", roleHandler.SetUserRoles)
 		}
-		payments := api.Group("/payments")
+		keys := api.Group("/keys", limitCredentials, authenticate, limitAdmin, can(auth.KeysManage))
 		{
-			payments.POST("/", paymentHandler.Create)
-			payments.GET("/", paymentHandler.GetAll)
-			payments.GET("/stream", paymentHandler.Stream)
-			payments.GET("/:id", paymentHandler.GetById)
-			payments.PUT("/:id", paymentHandler.Update)
-			payments.DELETE("/:id", paymentHandler.Delete)
+			keys.GET("/", keyHandler.GetAll)
+			keys.POST("/", keyHandler.Create)
+			keys.DELETE("/:id", keyHandler.Revoke)
+		}
+		api.GET("/audit", limitCredentials, authenticate, limitAdmin, can(auth.AuditRead), auditHandler.GetAll)
+	}
+
+	//unversioned routes stay for existing clients, with the v1 contract
+	routes(r.Group("/api", handler.Version(1)))
+	routes(r.Group("/api/v1", handler.Version(1)))
+	routes(r.Group("/api/v2", handler.Version(2)))
+
+	//the requests still running once the drain timeout is over are cancelled,
+	//with their queries
+	requestsCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
+	server := &http.Server{
+		Addr:        cfg.HTTP.Addr,
+		Handler:     r,
+		BaseContext: func(net.Listener) context.Context { return requestsCtx },
+	}
+	//streams never finish on their own, end them once no new request comes in
+	server.RegisterOnShutdown(func() {
+		broadcaster.Close()
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	//both addresses are bound before serving, so that nothing is left running
+	//when one of them is taken
+	listener, err := net.Listen("tcp", cfg.HTTP.Addr)
+	if err != nil {
+		return err
+	}
+	//the metrics tell about every tenant, they stay off the public listener
+	metricsServer := &http.Server{Addr: cfg.HTTP.MetricsAddr, Handler: metrics.Handler()}
+	var metricsListener net.Listener
+	if cfg.HTTP.MetricsAddr != "" {
+		metricsListener, err = net.Listen("tcp", cfg.HTTP.MetricsAddr)
+		if err != nil {
+			listener.Close()
+			return err
 		}
 	}
 
-	r.Run(":3333")
+	serveErr := make(chan error, 2)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+	if metricsListener != nil {
+		go func() {
+			serveErr <- metricsServer.Serve(metricsListener)
+		}()
+		slog.Info("serving metrics", "addr", metricsListener.Addr().String())
+	}
+
+	slog.Info("Ca tourne !", "addr", listener.Addr().String(), "version", version)
+
+	//when a server fails, the other one is shut down all the same
+	var failed error
+	select {
+	case failed = <-serveErr:
+		slog.Error("server failed, shutting down", "error", failed.Error())
+	case <-ctx.Done():
+		stop()
+		slog.Info("shutting down")
+		healthHandler.Drain()
+		time.Sleep(cfg.HTTP.ShutdownDelay)
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.DrainTimeout)
+	defer cancel()
+
+	err = server.Shutdown(drainCtx)
+	if err != nil {
+		slog.Error("shutdown failed", "error", err.Error())
+	}
+	cancelRequests()
+
+	err = metricsServer.Shutdown(drainCtx)
+	if err != nil {
+		slog.Error("metrics shutdown failed", "error", err.Error())
+	}
+
+	priceScheduler.Stop()
+
+	//the spans of the last requests, with a delay of their own
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = shutdownTracing(flushCtx)
+	if err != nil {
+		slog.Error("tracing shutdown failed", "error", err.Error())
+	}
 
-	fmt.Println("Ca tourne !")
+	return failed
 }
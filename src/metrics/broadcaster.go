@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"go/src/broadcaster"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// broadcasterCollector reads the stats of a broadcaster when scraped.
+type broadcasterCollector struct {
+	broadcaster broadcaster.Broadcaster
+
+	subscribers *prometheus.Desc
+	queued      *prometheus.Desc
+	submitted   *prometheus.Desc
+	dropped     *prometheus.Desc
+	missed      *prometheus.Desc
+}
+
+// RegisterBroadcaster exposes the stats of b.
+func RegisterBroadcaster(b broadcaster.Broadcaster) error {
+	name := func(name string) string {
+		return prometheus.BuildFQName(namespace, "broadcaster", name)
+	}
+
+	return Registry.Register(&broadcasterCollector{
+		broadcaster: b,
+		subscribers: prometheus.NewDesc(name("subscribers"), "Listeners of the stream registered.", nil, nil),
+		queued:      prometheus.NewDesc(name("queue_depth"), "Events submitted and not broadcast yet.", nil, nil),
+		submitted:   prometheus.NewDesc(name("events_submitted_total"), "Events accepted by Submit.", nil, nil),
+		dropped:     prometheus.NewDesc(name("events_dropped_total"), "Events refused by Submit, the queue being full or the broadcaster closed.", nil, nil),
+		missed:      prometheus.NewDesc(name("events_missed_total"), "Events not delivered to a listener that did not keep up.", nil, nil),
+	})
+}
+
+func (c *broadcasterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.subscribers
+	ch <- c.queued
+	ch <- c.submitted
+	ch <- c.dropped
+	ch <- c.missed
+}
+
+func (c *broadcasterCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.broadcaster.Stats()
+
+	ch <- prometheus.MustNewConstMetric(c.subscribers, prometheus.GaugeValue, float64(stats.Subscribers))
+	ch <- prometheus.MustNewConstMetric(c.queued, prometheus.GaugeValue, float64(stats.Queued))
+	ch <- prometheus.MustNewConstMetric(c.submitted, prometheus.CounterValue, float64(stats.Submitted))
+	ch <- prometheus.MustNewConstMetric(c.dropped, prometheus.CounterValue, float64(stats.Dropped))
+	ch <- prometheus.MustNewConstMetric(c.missed, prometheus.CounterValue, float64(stats.Missed))
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const startKey = "metrics:start"
+
+// GORM measures the queries run through a database, registered with
+// db.Use(metrics.GORM{}).
+type GORM struct{}
+
+func (GORM) Name() string {
+	return "metrics"
+}
+
+func (GORM) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	errs := []error{
+		callback.Create().Before("gorm:create").Register("metrics:before_create", start),
+		callback.Create().After("gorm:create").Register("metrics:after_create", observe("create")),
+		callback.Query().Before("gorm:query").Register("metrics:before_query", start),
+		callback.Query().After("gorm:query").Register("metrics:after_query", observe("query")),
+		callback.Update().Before("gorm:update").Register("metrics:before_update", start),
+		callback.Update().After("gorm:update").Register("metrics:after_update", observe("update")),
+		callback.Delete().Before("gorm:delete").Register("metrics:before_delete", start),
+		callback.Delete().After("gorm:delete").Register("metrics:after_delete", observe("delete")),
+		callback.Row().Before("gorm:row").Register("metrics:before_row", start),
+		callback.Row().After("gorm:row").Register("metrics:after_row", observe("row")),
+		callback.Raw().Before("gorm:raw").Register("metrics:before_raw", start),
+		callback.Raw().After("gorm:raw").Register("metrics:after_raw", observe("raw")),
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func start(db *gorm.DB) {
+	db.InstanceSet(startKey, time.Now())
+}
+
+func observe(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		start, ok := db.InstanceGet(startKey)
+		if !ok {
+			return
+		}
+		dbDuration.WithLabelValues(operation, db.Statement.Table).Observe(time.Since(start.(time.Time)).Seconds())
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/src/config"
+	"go/src/database"
+	"strconv"
+	"time"
+)
+
+var errMigrateUsage = errors.New("usage: migrate up|down|status|to <version> [flags]")
+
+// runMigrate runs the migrate subcommand: up applies every pending
+// migration, down rolls back the last one, to moves the schema to a version
+// (0 rolls everything back) and status lists them.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+	action, args := args[0], args[1:]
+
+	target := 0
+	if action == "to" {
+		if len(args) == 0 {
+			return errMigrateUsage
+		}
+		version, err := strconv.Atoi(args[0])
+		if err != nil {
+			return errMigrateUsage
+		}
+		target, args = version, args[1:]
+	}
+
+	cfg, err := config.Load(args)
+	if err != nil {
+		return err
+	}
+	err = setupLogging(cfg.Log)
+	if err != nil {
+		return err
+	}
+	if cfg.Database.Driver == "memory" {
+		return errors.New("the memory driver has no schema to migrate")
+	}
+
+	db, err := database.Open(cfg.Database)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	var done []database.Migration
+	switch action {
+	case "up":
+		done, err = migrator.Up()
+	case "down":
+		done, err = migrator.Down()
+	case "to":
+		done, err = migrator.To(target)
+	case "status":
+		return printStatus(migrator)
+	default:
+		return errMigrateUsage
+	}
+
+	for _, migration := range done {
+		fmt.Printf("%04d %s\n", migration.Version, migration.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(done) == 0 {
+		fmt.Println("nothing to migrate")
+	}
+	return nil
+}
+
+func printStatus(migrator database.Migrator) error {
+	status, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range status {
+		applied := "pending"
+		if migration.AppliedAt != nil {
+			applied = migration.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Printf("%04d %-30s %s\n", migration.Version, migration.Name, applied)
+	}
+	return nil
+}
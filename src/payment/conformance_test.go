@@ -0,0 +1,309 @@
+package payment_test
+
+import (
+	"context"
+	"errors"
+	"go/src/internal/storagetest"
+	"go/src/payment"
+	"go/src/product"
+	"go/src/tenant"
+	"testing"
+)
+
+var conformance = []struct {
+	name string
+	run  func(t *testing.T, ctx context.Context, s storagetest.Stores)
+}{
+	{"not found", testNotFound},
+	{"create", testCreate},
+	{"unknown product", testUnknownProduct},
+	{"update", testUpdate},
+	{"soft delete and restore", testSoftDelete},
+	{"tenants", testTenants},
+}
+
+// TestRepository runs the same cases against every backend, which must
+// behave alike.
+func TestRepository(t *testing.T) {
+	for _, backend := range storagetest.Backends {
+		for _, c := range conformance {
+			backend, c := backend, c
+			t.Run(backend.Name+"/"+c.name, func(t *testing.T) {
+				ctx := tenant.WithID(context.Background(), tenant.Default)
+				c.run(t, ctx, backend.Open(t))
+			})
+		}
+	}
+}
+
+func createProduct(t *testing.T, ctx context.Context, s storagetest.Stores, name string) product.Product {
+	t.Helper()
+	p, err := s.Products.Create(ctx, product.Product{Name: name, Price: 10})
+	if err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	return p
+}
+
+func create(t *testing.T, ctx context.Context, s storagetest.Stores, productID int, pricePaid float64) payment.Payment {
+	t.Helper()
+	p, err := s.Payments.Create(ctx, payment.Payment{ProductID: productID, PricePaid: pricePaid})
+	if err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+	return p
+}
+
+func wantErr(t *testing.T, what string, err error, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Errorf("%s: got error %v, want %v", what, err, target)
+	}
+}
+
+func testNotFound(t *testing.T, ctx context.Context, s storagetest.Stores) {
+	chair := createProduct(t, ctx, s, "chair")
+	p := create(t, ctx, s, chair.ID, 10)
+	missing := p.ID + 100
+
+	_, err := s.Payments.GetById(ctx, missing)
+	wantErr(t, "GetById", err, payment.ErrNotFound)
+	_, err = s.Payments.Update(ctx, missing, 0, payment.InputPayment{ProductID: chair.ID, PricePaid: 20})
+	wantErr(t, "Update", err, payment.ErrNotFound)
+	pricePaid := 20.0
+	_, err = s.Payments.Patch(ctx, missing, 0, payment.PatchPayment{PricePaid: &pricePaid})
+	wantErr(t, "Patch", err, payment.ErrNotFound)
+	err = s.Payments.Delete(ctx, missing, 0)
+	wantErr(t, "Delete", err, payment.ErrNotFound)
+	err = s.Payments.Delete(ctx, missing, 1)
+	wantErr(t, "Delete with a version", err, payment.ErrNotFound)
+
+	//only deleted payments can be restored
+	_, err = s.Payments.Restore(ctx, p.ID)
+	wantErr(t, "Restore", err, payment.ErrNotFound)
+}
+
+func testCreate(t *testing.T, ctx context.Context, s storagetest.Stores) {
+	chair := createProduct(t, ctx, s, "chair")
+	first := create(t, ctx, s, chair.ID, 10)
+	second := create(t, ctx, s, chair.ID, 12.5)
+
+	if first.ID == 0 || second.ID <= first.ID {
+		t.Errorf("got IDs %d and %d, want increasing IDs", first.ID, second.ID)
+	}
+	if first.Version != 1 {
+		t.Errorf("got version %d, want 1", first.Version)
+	}
+	if first.TenantID != tenant.Default {
+		t.Errorf("got tenant %q, want %q", first.TenantID, tenant.Default)
+	}
+	if first.CreatedAt.IsZero() || first.UpdatedAt.IsZero() {
+		t.Errorf("timestamps not set: created %v, updated %v", first.CreatedAt, first.UpdatedAt)
+	}
+	if first.Product == nil || first.Product.ID != chair.ID {
+		t.Errorf("got product %+v, want %d", first.Product, chair.ID)
+	}
+
+	got, err := s.Payments.GetById(ctx, second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PricePaid != 12.5 || got.ProductID != chair.ID || got.Version != 1 {
+		t.Errorf("got %+v, want 12.5 paid for %d in version 1", got, chair.ID)
+	}
+	if got.Product == nil || got.Product.Name != "chair" {
+		t.Errorf("got product %+v, want the chair", got.Product)
+	}
+	if !got.CreatedAt.Equal(second.CreatedAt) {
+		t.Errorf("got created_at %v, want %v", got.CreatedAt, second.CreatedAt)
+	}
+
+	payments, total, err := s.Payments.GetAll(ctx, payment.ListOptions{Page: 2, PerPage: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(payments) != 1 || payments[0].ID != second.ID {
+		t.Errorf("got page %+v of %d payments, want the second of 2", payments, total)
+	}
+}
+
+func testUnknownProduct(t *testing.T, ctx context.Context, s storagetest.Stores) {
+	chair := createProduct(t, ctx, s, "chair")
+	table := createProduct(t, ctx, s, "table")
+	err := s.Products.Delete(ctx, table.ID, 0, product.DeleteArchive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Payments.Create(ctx, payment.Payment{ProductID: table.ID + 100, PricePaid: 10})
+	wantErr(t, "Create for a missing product", err, payment.ErrUnknownProduct)
+	_, err = s.Payments.Create(ctx, payment.Payment{ProductID: table.ID, PricePaid: 10})
+	wantErr(t, "Create for a deleted product", err, payment.ErrUnknownProduct)
+
+	p := create(t, ctx, s, chair.ID, 10)
+	_, err = s.Payments.Update(ctx, p.ID, 0, payment.InputPayment{ProductID: table.ID, PricePaid: 20})
+	wantErr(t, "Update to a deleted product", err, payment.ErrUnknownProduct)
+	missing := table.ID + 100
+	_, err = s.Payments.Patch(ctx, p.ID, 0, payment.PatchPayment{ProductID: &missing})
+	wantErr(t, "Patch to a missing product", err, payment.ErrUnknownProduct)
+
+	got, err := s.Payments.GetById(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ProductID != chair.ID || got.PricePaid != 10 || got.Version != 1 {
+		t.Errorf("got %+v, want the payment untouched", got)
+	}
+
+	_, total, err := s.Payments.GetAll(ctx, payment.ListOptions{IncludeDeleted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 {
+		t.Errorf("stored %d payments, want the refused ones left out", total)
+	}
+}
+
+func testUpdate(t *testing.T, ctx context.Context, s storagetest.Stores) {
+	chair := createProduct(t, ctx, s, "chair")
+	table := createProduct(t, ctx, s, "table")
+	p := create(t, ctx, s, chair.ID, 10)
+
+	updated, err := s.Payments.Update(ctx, p.ID, p.Version, payment.InputPayment{ProductID: table.ID, PricePaid: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Version != 2 || updated.ProductID != table.ID || updated.PricePaid != 20 {
+		t.Errorf("got %+v, want 20 paid for %d in version 2", updated, table.ID)
+	}
+	if updated.Product == nil || updated.Product.ID != table.ID {
+		t.Errorf("got product %+v, want %d", updated.Product, table.ID)
+	}
+	if updated.UpdatedAt.Before(p.UpdatedAt) {
+		t.Errorf("updated_at went back from %v to %v", p.UpdatedAt, updated.UpdatedAt)
+	}
+
+	_, err = s.Payments.Update(ctx, p.ID, p.Version, payment.InputPayment{ProductID: chair.ID, PricePaid: 5})
+	wantErr(t, "Update with a stale version", err, payment.ErrVersionConflict)
+
+	pricePaid := 25.0
+	patched, err := s.Payments.Patch(ctx, p.ID, 0, payment.PatchPayment{PricePaid: &pricePaid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched.Version != 3 || patched.ProductID != table.ID || patched.PricePaid != 25 {
+		t.Errorf("got %+v, want 25 paid for %d in version 3", patched, table.ID)
+	}
+}
+
+func testSoftDelete(t *testing.T, ctx context.Context, s storagetest.Stores) {
+	chair := createProduct(t, ctx, s, "chair")
+	p := create(t, ctx, s, chair.ID, 10)
+
+	err := s.Payments.Delete(ctx, p.ID, p.Version+1)
+	wantErr(t, "Delete with a stale version", err, payment.ErrVersionConflict)
+
+	err = s.Payments.Delete(ctx, p.ID, p.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Payments.GetById(ctx, p.ID)
+	wantErr(t, "GetById of a deleted payment", err, payment.ErrNotFound)
+	err = s.Payments.Delete(ctx, p.ID, 0)
+	wantErr(t, "Delete of a deleted payment", err, payment.ErrNotFound)
+
+	_, total, err := s.Payments.GetAll(ctx, payment.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 0 {
+		t.Errorf("listed %d payments, want the deleted one left out", total)
+	}
+
+	payments, _, err := s.Payments.GetAll(ctx, payment.ListOptions{IncludeDeleted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payments) != 1 || !payments[0].DeletedAt.Valid || payments[0].Version != 2 {
+		t.Fatalf("got %+v, want the payment deleted in version 2", payments)
+	}
+
+	restored, err := s.Payments.Restore(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.DeletedAt.Valid || restored.Version != 3 {
+		t.Errorf("got %+v, want the payment restored in version 3", restored)
+	}
+	if restored.Product == nil || restored.Product.ID != chair.ID {
+		t.Errorf("got product %+v, want %d", restored.Product, chair.ID)
+	}
+
+	_, err = s.Payments.Restore(ctx, p.ID)
+	wantErr(t, "Restore of a restored payment", err, payment.ErrNotFound)
+}
+
+func testTenants(t *testing.T, ctx context.Context, s storagetest.Stores) {
+	acme := tenant.WithID(ctx, "acme")
+	globex := tenant.WithID(ctx, "globex")
+	chair := createProduct(t, acme, s, "chair")
+	table := createProduct(t, globex, s, "table")
+	p := create(t, acme, s, chair.ID, 10)
+
+	_, err := s.Payments.Create(globex, payment.Payment{ProductID: chair.ID, PricePaid: 10})
+	wantErr(t, "Create for the product of acme", err, payment.ErrUnknownProduct)
+	_, err = s.Payments.Update(acme, p.ID, 0, payment.InputPayment{ProductID: table.ID, PricePaid: 10})
+	wantErr(t, "Update to the product of globex", err, payment.ErrUnknownProduct)
+	_, err = s.Payments.Patch(acme, p.ID, 0, payment.PatchPayment{ProductID: &table.ID})
+	wantErr(t, "Patch to the product of globex", err, payment.ErrUnknownProduct)
+
+	_, err = s.Payments.GetById(globex, p.ID)
+	wantErr(t, "GetById", err, payment.ErrNotFound)
+	_, total, err := s.Payments.GetAll(globex, payment.ListOptions{IncludeDeleted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 0 {
+		t.Errorf("globex listed %d payments, want none", total)
+	}
+	_, total, err = s.Payments.GetAll(globex, payment.ListOptions{ProductID: chair.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 0 {
+		t.Errorf("globex listed %d payments of the chair, want none", total)
+	}
+
+	_, err = s.Payments.Update(globex, p.ID, 0, payment.InputPayment{ProductID: table.ID, PricePaid: 20})
+	wantErr(t, "Update", err, payment.ErrNotFound)
+	pricePaid := 20.0
+	_, err = s.Payments.Patch(globex, p.ID, 0, payment.PatchPayment{PricePaid: &pricePaid})
+	wantErr(t, "Patch", err, payment.ErrNotFound)
+	err = s.Payments.Delete(globex, p.ID, 0)
+	wantErr(t, "Delete", err, payment.ErrNotFound)
+	err = s.Payments.Delete(globex, p.ID, p.Version)
+	wantErr(t, "Delete with a version", err, payment.ErrNotFound)
+
+	err = s.Payments.Delete(acme, p.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Payments.Restore(globex, p.ID)
+	wantErr(t, "Restore", err, payment.ErrNotFound)
+
+	//nothing globex tried reached the payment of acme
+	restored, err := s.Payments.Restore(acme, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.ProductID != chair.ID || restored.PricePaid != 10 || restored.Version != 3 {
+		t.Errorf("got %+v, want 10 paid for the chair in version 3", restored)
+	}
+
+	//a context without tenant reaches nothing
+	_, err = s.Payments.GetById(context.Background(), p.ID)
+	wantErr(t, "GetById without tenant", err, tenant.ErrMissing)
+	_, err = s.Payments.Create(context.Background(), payment.Payment{ProductID: chair.ID, PricePaid: 10})
+	wantErr(t, "Create without tenant", err, tenant.ErrMissing)
+}
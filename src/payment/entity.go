@@ -3,13 +3,18 @@ package payment
 import (
 	"go/src/product"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Payment struct {
 	ID        int              `json:"id"`
+	TenantID  string           `json:"-"`
 	ProductID int              `json:"product_id"`
-	Product   *product.Product `json:"product"`
+	Product   *product.Product `json:"product" gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;"`
 	PricePaid float64          `json:"price_paid"`
+	Version   int              `json:"version" gorm:"not null;default:1"`
 	CreatedAt time.Time        `json:"created_at"`
 	UpdatedAt time.Time        `json:"updated_at"`
+	DeletedAt gorm.DeletedAt   `json:"deleted_at"`
 }
@@ -0,0 +1,10 @@
+package payment
+
+import "go/src/apperror"
+
+var (
+	ErrNotFound        = apperror.NewNotFound("payment_not_found", "payment not found")
+	ErrUnknownProduct  = apperror.NewValidation("unknown_product", "product does not exist")
+	ErrInvalidPrice    = apperror.NewValidation("invalid_price_paid", "price paid must not be negative")
+	ErrVersionConflict = apperror.NewPreconditionFailed("payment_modified", "payment was modified concurrently")
+)
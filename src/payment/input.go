@@ -1,6 +1,21 @@
 package payment
 
 type InputPayment struct {
-	ProductID int     `json:"productid" binding:"required"`
-	PricePaid float64 `json:"pricepaid" binding:"required"`
+	ProductID int     `json:"product_id" binding:"required"`
+	PricePaid float64 `json:"price_paid" binding:"required"`
+}
+
+// PatchPayment is a JSON Merge Patch of a payment, nil fields are left untouched.
+type PatchPayment struct {
+	ProductID *int     `json:"product_id" binding:"omitempty,gt=0"`
+	PricePaid *float64 `json:"price_paid" binding:"omitempty,gte=0"`
+}
+
+// ListOptions selects the payments to list. A zero PerPage lists all of them,
+// a non zero ProductID only the payments of that product.
+type ListOptions struct {
+	IncludeDeleted bool
+	ProductID      int
+	Page           int
+	PerPage        int
 }
@@ -0,0 +1,302 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	Product "go/src/product"
+	"go/src/tenant"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// memoryProducts is what the in-memory payments need from the in-memory
+// products.
+type memoryProducts interface {
+	View(ctx context.Context, id int, fn func(product Product.Product) error) error
+	Lookup(id int) (Product.Product, bool)
+	Register(dependents Product.Dependents)
+}
+
+// memoryRepository keeps the payments in memory, for tests and demos. Its
+// products come from the in-memory product repository, which takes its lock
+// before this one.
+type memoryRepository struct {
+	mu       sync.RWMutex
+	products memoryProducts
+	payments map[int]Payment
+	lastID   int
+}
+
+func NewMemoryRepository(products memoryProducts) *memoryRepository {
+	r := &memoryRepository{
+		products: products,
+		payments: make(map[int]Payment),
+	}
+	products.Register(r)
+	return r
+}
+
+func (r *memoryRepository) Create(ctx context.Context, payment Payment) (Payment, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return payment, err
+	}
+
+	//the product can not be deleted until the payment is stored
+	err = r.products.View(ctx, payment.ProductID, func(product Product.Product) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		now := time.Now()
+		r.lastID++
+		payment.ID = r.lastID
+		payment.TenantID = tenantID
+		payment.Version = 1
+		payment.CreatedAt = now
+		payment.UpdatedAt = now
+		payment.DeletedAt = gorm.DeletedAt{}
+		payment.Product = nil
+
+		r.payments[payment.ID] = payment
+		payment.Product = &product
+		return nil
+	})
+	if err != nil {
+		return payment, unknownMemoryProduct(err)
+	}
+
+	return payment, nil
+}
+
+func (r *memoryRepository) GetAll(ctx context.Context, options ListOptions) ([]Payment, int64, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	r.mu.RLock()
+	payments := []Payment{}
+	for _, payment := range r.payments {
+		if payment.TenantID != tenantID {
+			continue
+		}
+		if payment.DeletedAt.Valid && !options.IncludeDeleted {
+			continue
+		}
+		if options.ProductID != 0 && payment.ProductID != options.ProductID {
+			continue
+		}
+		payments = append(payments, payment)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(payments, func(i, j int) bool {
+		return payments[i].ID < payments[j].ID
+	})
+
+	total := int64(len(payments))
+	if options.PerPage > 0 {
+		start := (options.Page - 1) * options.PerPage
+		if start > len(payments) {
+			start = len(payments)
+		}
+		end := start + options.PerPage
+		if end > len(payments) {
+			end = len(payments)
+		}
+		payments = payments[start:end]
+	}
+
+	for i := range payments {
+		r.withProduct(&payments[i])
+	}
+
+	return payments, total, nil
+}
+
+func (r *memoryRepository) GetById(ctx context.Context, id int) (Payment, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return Payment{}, err
+	}
+
+	r.mu.RLock()
+	payment, err := r.get(tenantID, id)
+	r.mu.RUnlock()
+	if err != nil {
+		return payment, err
+	}
+
+	r.withProduct(&payment)
+	return payment, nil
+}
+
+func (r *memoryRepository) Update(ctx context.Context, id int, version int, input InputPayment) (Payment, error) {
+	return r.update(ctx, id, version, &input.ProductID, &input.PricePaid)
+}
+
+func (r *memoryRepository) Patch(ctx context.Context, id int, version int, patch PatchPayment) (Payment, error) {
+	return r.update(ctx, id, version, patch.ProductID, patch.PricePaid)
+}
+
+func (r *memoryRepository) update(ctx context.Context, id int, version int, productID *int, pricePaid *float64) (Payment, error) {
+	var payment Payment
+
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return payment, err
+	}
+
+	apply := func() error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		var err error
+		payment, err = r.get(tenantID, id)
+		if err != nil {
+			return err
+		}
+
+		if version != 0 && payment.Version != version {
+			return ErrVersionConflict
+		}
+
+		if productID != nil {
+			payment.ProductID = *productID
+		}
+		if pricePaid != nil {
+			payment.PricePaid = *pricePaid
+		}
+		payment.Version++
+		payment.UpdatedAt = time.Now()
+
+		r.payments[id] = payment
+		return nil
+	}
+
+	if productID == nil {
+		err := apply()
+		if err != nil {
+			return payment, err
+		}
+		r.withProduct(&payment)
+		return payment, nil
+	}
+
+	//keep the new product from being deleted until the payment references it
+	err = r.products.View(ctx, *productID, func(product Product.Product) error {
+		err := apply()
+		payment.Product = &product
+		return err
+	})
+	if errors.Is(err, Product.ErrNotFound) {
+		//report a missing or stale payment first, like the GORM repository
+		current, err := r.GetById(ctx, id)
+		if err != nil {
+			return current, err
+		}
+		if version != 0 && current.Version != version {
+			return current, ErrVersionConflict
+		}
+		return current, ErrUnknownProduct
+	}
+	if err != nil {
+		return payment, err
+	}
+
+	return payment, nil
+}
+
+func (r *memoryRepository) Delete(ctx context.Context, id int, version int) error {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	payment, err := r.get(tenantID, id)
+	if err != nil {
+		return err
+	}
+
+	if version != 0 && payment.Version != version {
+		return ErrVersionConflict
+	}
+
+	now := time.Now()
+	payment.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+	payment.Version++
+	payment.UpdatedAt = now
+	r.payments[id] = payment
+
+	return nil
+}
+
+func (r *memoryRepository) Restore(ctx context.Context, id int) (Payment, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return Payment{}, err
+	}
+
+	r.mu.Lock()
+	payment, ok := r.payments[id]
+	if !ok || payment.TenantID != tenantID || !payment.DeletedAt.Valid {
+		r.mu.Unlock()
+		return Payment{}, ErrNotFound
+	}
+
+	payment.DeletedAt = gorm.DeletedAt{}
+	payment.Version++
+	payment.UpdatedAt = time.Now()
+	r.payments[id] = payment
+	r.mu.Unlock()
+
+	r.withProduct(&payment)
+	return payment, nil
+}
+
+// CountByProduct counts the payments of the product, for the block policy.
+func (r *memoryRepository) CountByProduct(productID int) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, payment := range r.payments {
+		if payment.ProductID == productID && !payment.DeletedAt.Valid {
+			count++
+		}
+	}
+	return count
+}
+
+// get returns the payment unless it is missing, deleted or of another
+// tenant, the caller holds the lock.
+func (r *memoryRepository) get(tenantID string, id int) (Payment, error) {
+	payment, ok := r.payments[id]
+	if !ok || payment.TenantID != tenantID || payment.DeletedAt.Valid {
+		return Payment{}, ErrNotFound
+	}
+	return payment, nil
+}
+
+// withProduct attaches the product of the payment, even deleted, like the
+// preload of the GORM repository.
+func (r *memoryRepository) withProduct(payment *Payment) {
+	product, ok := r.products.Lookup(payment.ProductID)
+	if ok {
+		payment.Product = &product
+	}
+}
+
+// unknownMemoryProduct turns a missing product into ErrUnknownProduct.
+func unknownMemoryProduct(err error) error {
+	if errors.Is(err, Product.ErrNotFound) {
+		return ErrUnknownProduct
+	}
+	return err
+}
@@ -1,18 +1,27 @@
 package payment
 
 import (
+	"context"
 	"errors"
+	"go/src/database"
 	Product "go/src/product"
+	"go/src/tenant"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// Repository only reaches the payments, and the products, of the tenant of
+// ctx.
 type Repository interface {
-	Create(payment Payment) (Payment, error)
-	GetAll() ([]Payment, error)
-	GetById(id int) (Payment, error)
-	Update(id int, input InputPayment) (Payment, error)
-	Delete(id int) error
+	Create(ctx context.Context, payment Payment) (Payment, error)
+	GetAll(ctx context.Context, options ListOptions) ([]Payment, int64, error)
+	GetById(ctx context.Context, id int) (Payment, error)
+	Update(ctx context.Context, id int, version int, input InputPayment) (Payment, error)
+	Patch(ctx context.Context, id int, version int, patch PatchPayment) (Payment, error)
+	Delete(ctx context.Context, id int, version int) error
+	Restore(ctx context.Context, id int) (Payment, error)
 }
 
 type repository struct {
@@ -23,14 +32,23 @@ func NewRepository(db *gorm.DB) *repository {
 	return &repository{db}
 }
 
-func (r *repository) Create(payment Payment) (Payment, error) {
-	//verify that product exists
-	err := r.db.Where("id = ?", payment.ProductID).First(&payment.Product).Error
+func (r *repository) Create(ctx context.Context, payment Payment) (Payment, error) {
+	tenantID, err := tenant.Require(ctx)
 	if err != nil {
 		return payment, err
 	}
-
-	err = r.db.Create(&payment).Error
+	payment.TenantID = tenantID
+	payment.Version = 1
+
+	err = database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		//verify that product exists, and keep it from being deleted until commit
+		err := tx.Scopes(tenant.Scope, database.ForShare).Where("id = ?", payment.ProductID).First(&payment.Product).Error
+		if err != nil {
+			return unknownProduct(err)
+		}
+
+		return tx.Omit(clause.Associations).Create(&payment).Error
+	})
 	if err != nil {
 		return payment, err
 	}
@@ -38,46 +56,102 @@ func (r *repository) Create(payment Payment) (Payment, error) {
 	return payment, nil
 }
 
-func (r *repository) GetAll() ([]Payment, error) {
+func (r *repository) GetAll(ctx context.Context, options ListOptions) ([]Payment, int64, error) {
 	var payments []Payment
+	var total int64
+
+	db := database.Conn(ctx, r.db).Scopes(tenant.Scope)
+	if options.IncludeDeleted {
+		db = db.Unscoped()
+	}
+	if options.ProductID != 0 {
+		db = db.Where("product_id = ?", options.ProductID)
+	}
+	db = db.Session(&gorm.Session{})
+
+	err := db.Model(&Payment{}).Count(&total).Error
+	if err != nil {
+		return payments, total, err
+	}
+
+	if options.PerPage > 0 {
+		db = db.Limit(options.PerPage).Offset((options.Page - 1) * options.PerPage)
+	}
+
 	//preload => load products linked
-	err := r.db.Preload("Product").Find(&payments).Error
+	err = db.Preload("Product", unscoped).Order("id").Find(&payments).Error
 	if err != nil {
-		return payments, err
+		return payments, total, err
 	}
 
-	return payments, nil
+	return payments, total, nil
 }
 
-func (r *repository) GetById(id int) (Payment, error) {
+func (r *repository) GetById(ctx context.Context, id int) (Payment, error) {
 	var payment Payment
 
 	//preload => load products linked
-	err := r.db.Preload("Product").Where(&Payment{ID: id}).First(&payment).Error
+	err := database.Conn(ctx, r.db).Scopes(tenant.Scope).Preload("Product", unscoped).Where(&Payment{ID: id}).First(&payment).Error
 	if err != nil {
-		return payment, err
+		return payment, notFound(err)
 	}
 
 	return payment, nil
 }
 
-func (r *repository) Update(id int, input InputPayment) (Payment, error) {
-	payment, err := r.GetById(id)
-	if err != nil {
-		return payment, err
-	}
+func (r *repository) Update(ctx context.Context, id int, version int, input InputPayment) (Payment, error) {
+	return r.update(ctx, id, version, &input.ProductID, &input.PricePaid)
+}
 
-	//get the linked product
-	var product Product.Product
-	err = r.db.Where(&Product.Product{ID: input.ProductID}).First(&product).Error
-	if err != nil {
-		return payment, err
-	}
+func (r *repository) Patch(ctx context.Context, id int, version int, patch PatchPayment) (Payment, error) {
+	return r.update(ctx, id, version, patch.ProductID, patch.PricePaid)
+}
+
+// update sets the non nil fields of the payment, checking that a new
+// product exists within the same transaction. A version of 0 skips the
+// If-Match check, the write still fails if the payment changed meanwhile.
+func (r *repository) update(ctx context.Context, id int, version int, productID *int, pricePaid *float64) (Payment, error) {
+	var payment Payment
 
-	payment.ProductID = input.ProductID
-	payment.PricePaid = input.PricePaid
-	err = r.db.Save(&payment).Error
-	// err = r.db.Model(&payment).Updates(Payment{ProductID: input.ProductID, PricePaid: input.PricePaid}).Error
+	err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		err := tx.Scopes(tenant.Scope).Preload("Product", unscoped).Where(&Payment{ID: id}).First(&payment).Error
+		if err != nil {
+			return notFound(err)
+		}
+
+		if version != 0 && payment.Version != version {
+			return ErrVersionConflict
+		}
+
+		if productID != nil {
+			//get the linked product
+			var product Product.Product
+			err = tx.Scopes(tenant.Scope, database.ForShare).Where(&Product.Product{ID: *productID}).First(&product).Error
+			if err != nil {
+				return unknownProduct(err)
+			}
+
+			payment.ProductID = *productID
+			payment.Product = &product
+		}
+		if pricePaid != nil {
+			payment.PricePaid = *pricePaid
+		}
+
+		current := payment.Version
+		payment.Version++
+
+		result := tx.Model(&payment).Where("version = ?", current).
+			Select("*").Omit(clause.Associations).Updates(&payment)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+
+		return nil
+	})
 	if err != nil {
 		return payment, err
 	}
@@ -85,16 +159,73 @@ func (r *repository) Update(id int, input InputPayment) (Payment, error) {
 	return payment, nil
 }
 
-func (r *repository) Delete(id int) error {
-	payment := &Payment{ID: id}
-	tx := r.db.Delete(payment)
+// Delete soft deletes the payment as its next version, so that the ETags
+// of the deleted payment no longer match.
+func (r *repository) Delete(ctx context.Context, id int, version int) error {
+	db := database.Conn(ctx, r.db).Model(&Payment{}).Scopes(tenant.Scope).Where("id = ?", id)
+	if version != 0 {
+		db = db.Where("version = ?", version)
+	}
+
+	tx := db.Updates(map[string]interface{}{
+		"deleted_at": time.Now(),
+		"version":    gorm.Expr("version + 1"),
+	})
 	if tx.Error != nil {
 		return tx.Error
 	}
 
 	if tx.RowsAffected == 0 {
-		return errors.New("Payment not found")
+		if version != 0 {
+			//tell a stale version apart from a missing payment
+			_, err := r.GetById(ctx, id)
+			if err == nil {
+				return ErrVersionConflict
+			}
+		}
+		return ErrNotFound
 	}
 
 	return nil
 }
+
+func (r *repository) Restore(ctx context.Context, id int) (Payment, error) {
+	var payment Payment
+
+	db := database.Conn(ctx, r.db).Scopes(tenant.Scope).Unscoped().Session(&gorm.Session{})
+	err := db.Where("id = ? AND deleted_at IS NOT NULL", id).First(&payment).Error
+	if err != nil {
+		return payment, notFound(err)
+	}
+
+	err = db.Model(&payment).Updates(map[string]interface{}{
+		"deleted_at": nil,
+		"version":    gorm.Expr("version + 1"),
+	}).Error
+	if err != nil {
+		return payment, err
+	}
+
+	return r.GetById(ctx, id)
+}
+
+// notFound turns a missing payment into ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// unknownProduct turns a missing product into ErrUnknownProduct.
+func unknownProduct(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrUnknownProduct
+	}
+	return err
+}
+
+// unscoped keeps soft deleted products visible on the payments referencing them.
+func unscoped(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
@@ -1,45 +1,81 @@
 package payment
 
+import (
+	"context"
+	"errors"
+	"go/src/audit"
+	"go/src/database"
+	"go/src/metrics"
+
+	"golang.org/x/exp/slog"
+)
+
+const auditEntity = "payment"
+
+// changeRetries is how many times a change asked without a version is
+// retried when another request changes the payment between the audit
+// snapshot and the change.
+const changeRetries = 3
+
 type Service interface {
-	Create(input InputPayment) (Payment, error)
-	GetAll() ([]Payment, error)
-	GetById(id int) (Payment, error)
-	Update(id int, input InputPayment) (Payment, error)
-	Delete(id int) error
+	Create(ctx context.Context, input InputPayment) (Payment, error)
+	GetAll(ctx context.Context, options ListOptions) ([]Payment, int64, error)
+	GetById(ctx context.Context, id int) (Payment, error)
+	Update(ctx context.Context, id int, version int, input InputPayment) (Payment, error)
+	Patch(ctx context.Context, id int, version int, patch PatchPayment) (Payment, error)
+	Delete(ctx context.Context, id int, version int) error
+	Restore(ctx context.Context, id int) (Payment, error)
 }
 
 type service struct {
 	repository Repository
+	tx         database.Transactor
+	audit      audit.Recorder
 }
 
-func NewService(r Repository) *service {
-	return &service{r}
+// NewService records each change in the audit log within its transaction.
+func NewService(r Repository, tx database.Transactor, recorder audit.Recorder) *service {
+	return &service{r, tx, recorder}
 }
 
-func (s *service) Create(input InputPayment) (Payment, error) {
+func (s *service) Create(ctx context.Context, input InputPayment) (Payment, error) {
 	var payment Payment
+	if input.PricePaid < 0 {
+		return payment, ErrInvalidPrice
+	}
+
 	payment.ProductID = input.ProductID
 	payment.PricePaid = input.PricePaid
 
-	newPayment, err := s.repository.Create(payment)
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		payment, err = s.repository.Create(ctx, payment)
+		if err != nil {
+			return err
+		}
+
+		return s.audit.Record(ctx, audit.Create, auditEntity, payment.ID, nil, snapshot(payment))
+	})
 	if err != nil {
-		return newPayment, err
+		return payment, err
 	}
 
-	return newPayment, nil
+	slog.InfoCtx(ctx, "payment created", "payment_id", payment.ID, "product_id", payment.ProductID)
+	metrics.PaymentCreated(payment.TenantID, payment.PricePaid)
+	return payment, nil
 }
 
-func (s *service) GetAll() ([]Payment, error) {
-	payments, err := s.repository.GetAll()
+func (s *service) GetAll(ctx context.Context, options ListOptions) ([]Payment, int64, error) {
+	payments, total, err := s.repository.GetAll(ctx, options)
 	if err != nil {
-		return payments, err
+		return payments, total, err
 	}
 
-	return payments, nil
+	return payments, total, nil
 }
 
-func (s *service) GetById(id int) (Payment, error) {
-	payment, err := s.repository.GetById(id)
+func (s *service) GetById(ctx context.Context, id int) (Payment, error) {
+	payment, err := s.repository.GetById(ctx, id)
 	if err != nil {
 		return payment, err
 	}
@@ -47,22 +83,136 @@ func (s *service) GetById(id int) (Payment, error) {
 	return payment, nil
 }
 
-func (s *service) Update(id int, input InputPayment) (Payment, error) {
-	updatePayment, err := s.repository.Update(id, input)
+func (s *service) Update(ctx context.Context, id int, version int, input InputPayment) (Payment, error) {
+	if input.PricePaid < 0 {
+		return Payment{}, ErrInvalidPrice
+	}
+
+	updatePayment, err := s.change(ctx, id, version, audit.Update, func(ctx context.Context, version int) (Payment, error) {
+		return s.repository.Update(ctx, id, version, input)
+	})
 	if err != nil {
 		return updatePayment, err
 	}
 
+	slog.InfoCtx(ctx, "payment updated", "payment_id", id)
 	return updatePayment, nil
 }
 
-func (s *service) Delete(id int) error {
-	err := s.repository.Delete(id)
+func (s *service) Patch(ctx context.Context, id int, version int, patch PatchPayment) (Payment, error) {
+	if patch.PricePaid != nil && *patch.PricePaid < 0 {
+		return Payment{}, ErrInvalidPrice
+	}
+
+	payment, err := s.change(ctx, id, version, audit.Update, func(ctx context.Context, version int) (Payment, error) {
+		return s.repository.Patch(ctx, id, version, patch)
+	})
+	if err != nil {
+		return payment, err
+	}
+
+	slog.InfoCtx(ctx, "payment updated", "payment_id", id)
+	return payment, nil
+}
+
+func (s *service) Delete(ctx context.Context, id int, version int) error {
+	_, err := s.change(ctx, id, version, audit.Delete, func(ctx context.Context, version int) (Payment, error) {
+		return Payment{}, s.repository.Delete(ctx, id, version)
+	})
 	if err != nil {
 		return err
 	}
 
+	slog.InfoCtx(ctx, "payment deleted", "payment_id", id)
 	return nil
 }
 
+// DeleteByProduct deletes the payments of a product deleted with the cascade
+// policy, each as a deletion of its own in the audit log.
+func (s *service) DeleteByProduct(ctx context.Context, productID int) error {
+	return s.tx.Transaction(ctx, func(ctx context.Context) error {
+		payments, _, err := s.repository.GetAll(ctx, ListOptions{ProductID: productID})
+		if err != nil {
+			return err
+		}
+
+		for _, payment := range payments {
+			err := s.repository.Delete(ctx, payment.ID, payment.Version)
+			if err != nil {
+				return err
+			}
+
+			err = s.audit.Record(ctx, audit.Delete, auditEntity, payment.ID, snapshot(payment), nil)
+			if err != nil {
+				return err
+			}
+			slog.InfoCtx(ctx, "payment deleted", "payment_id", payment.ID, "product_id", productID)
+		}
+		return nil
+	})
+}
+
+func (s *service) Restore(ctx context.Context, id int) (Payment, error) {
+	var payment Payment
+
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		payment, err = s.repository.Restore(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		return s.audit.Record(ctx, audit.Restore, auditEntity, id, nil, snapshot(payment))
+	})
+	if err != nil {
+		return payment, err
+	}
+
+	slog.InfoCtx(ctx, "payment restored", "payment_id", id)
+	return payment, nil
+}
+
+// change reads the payment for the audit, then applies the change at the
+// version read, so that the payment changed is the one read, and records it
+// in the same transaction. A deletion is recorded without the payment after.
+func (s *service) change(ctx context.Context, id int, version int, action audit.Action, apply func(ctx context.Context, version int) (Payment, error)) (Payment, error) {
+	for attempt := 0; ; attempt++ {
+		var after Payment
+
+		err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+			before, err := s.repository.GetById(ctx, id)
+			if err != nil {
+				return err
+			}
+
+			expected := version
+			if expected == 0 {
+				expected = before.Version
+			}
+
+			after, err = apply(ctx, expected)
+			if err != nil {
+				return err
+			}
+
+			if action == audit.Delete {
+				return s.audit.Record(ctx, action, auditEntity, id, snapshot(before), nil)
+			}
+			return s.audit.Record(ctx, action, auditEntity, id, snapshot(before), snapshot(after))
+		})
+		if errors.Is(err, ErrVersionConflict) && version == 0 && attempt < changeRetries {
+			slog.DebugCtx(ctx, "payment changed concurrently, retrying", "payment_id", id, "attempt", attempt+1)
+			continue
+		}
+		return after, err
+	}
+}
+
+// snapshot is the payment as audited, without the product it embeds, which
+// has its own entries.
+func snapshot(payment Payment) Payment {
+	payment.Product = nil
+	return payment
+}
+
 // TODO Stream
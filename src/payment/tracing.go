@@ -0,0 +1,72 @@
+package payment
+
+import (
+	"context"
+	"go/src/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// tracedService traces each call to the Service it wraps in a span of its
+// own, under which the queries of the call are traced.
+type tracedService struct {
+	service Service
+}
+
+func NewTracedService(s Service) *tracedService {
+	return &tracedService{s}
+}
+
+func paymentID(id int) attribute.KeyValue {
+	return attribute.Int("payment.id", id)
+}
+
+func (t *tracedService) Create(ctx context.Context, input InputPayment) (Payment, error) {
+	ctx, span := tracing.Start(ctx, "payment.Service/Create", attribute.Int("product.id", input.ProductID))
+	payment, err := t.service.Create(ctx, input)
+	span.SetAttributes(paymentID(payment.ID))
+	tracing.End(span, err)
+	return payment, err
+}
+
+func (t *tracedService) GetAll(ctx context.Context, options ListOptions) ([]Payment, int64, error) {
+	ctx, span := tracing.Start(ctx, "payment.Service/GetAll")
+	payments, total, err := t.service.GetAll(ctx, options)
+	tracing.End(span, err)
+	return payments, total, err
+}
+
+func (t *tracedService) GetById(ctx context.Context, id int) (Payment, error) {
+	ctx, span := tracing.Start(ctx, "payment.Service/GetById", paymentID(id))
+	payment, err := t.service.GetById(ctx, id)
+	tracing.End(span, err)
+	return payment, err
+}
+
+func (t *tracedService) Update(ctx context.Context, id int, version int, input InputPayment) (Payment, error) {
+	ctx, span := tracing.Start(ctx, "payment.Service/Update", paymentID(id))
+	payment, err := t.service.Update(ctx, id, version, input)
+	tracing.End(span, err)
+	return payment, err
+}
+
+func (t *tracedService) Patch(ctx context.Context, id int, version int, patch PatchPayment) (Payment, error) {
+	ctx, span := tracing.Start(ctx, "payment.Service/Patch", paymentID(id))
+	payment, err := t.service.Patch(ctx, id, version, patch)
+	tracing.End(span, err)
+	return payment, err
+}
+
+func (t *tracedService) Delete(ctx context.Context, id int, version int) error {
+	ctx, span := tracing.Start(ctx, "payment.Service/Delete", paymentID(id))
+	err := t.service.Delete(ctx, id, version)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedService) Restore(ctx context.Context, id int) (Payment, error) {
+	ctx, span := tracing.Start(ctx, "payment.Service/Restore", paymentID(id))
+	payment, err := t.service.Restore(ctx, id)
+	tracing.End(span, err)
+	return payment, err
+}
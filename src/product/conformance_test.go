@@ -0,0 +1,375 @@
+package product_test
+
+import (
+	"context"
+	"errors"
+	"go/src/audit"
+	"go/src/internal/storagetest"
+	"go/src/payment"
+	"go/src/product"
+	"go/src/tenant"
+	"testing"
+	"time"
+)
+
+var conformance = []struct {
+	name string
+	run  func(t *testing.T, ctx context.Context, s storagetest.Stores)
+}{
+	{"not found", testNotFound},
+	{"create", testCreate},
+	{"update", testUpdate},
+	{"soft delete and restore", testSoftDelete},
+	{"block policy", testBlockPolicy},
+	{"archive policy", testArchivePolicy},
+	{"cascade policy", testCascadePolicy},
+	{"tenants", testTenants},
+}
+
+// TestRepository runs the same cases against every backend, which must
+// behave alike.
+func TestRepository(t *testing.T) {
+	for _, backend := range storagetest.Backends {
+		for _, c := range conformance {
+			backend, c := backend, c
+			t.Run(backend.Name+"/"+c.name, func(t *testing.T) {
+				ctx := tenant.WithID(context.Background(), tenant.Default)
+				c.run(t, ctx, backend.Open(t))
+			})
+		}
+	}
+}
+
+func create(t *testing.T, ctx context.Context, s storagetest.Stores, name string, price float64) product.Product {
+	t.Helper()
+	p, err := s.Products.Create(ctx, product.Product{Name: name, Price: price})
+	if err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	return p
+}
+
+func wantErr(t *testing.T, what string, err error, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Errorf("%s: got error %v, want %v", what, err, target)
+	}
+}
+
+func testNotFound(t *testing.T, ctx context.Context, s storagetest.Stores) {
+	p := create(t, ctx, s, "chair", 10)
+	missing := p.ID + 100
+
+	_, err := s.Products.GetById(ctx, missing)
+	wantErr(t, "GetById", err, product.ErrNotFound)
+	_, err = s.Products.Update(ctx, missing, 0, product.InputProduct{Name: "table", Price: 20})
+	wantErr(t, "Update", err, product.ErrNotFound)
+	name := "table"
+	_, err = s.Products.Patch(ctx, missing, 0, product.PatchProduct{Name: &name})
+	wantErr(t, "Patch", err, product.ErrNotFound)
+	err = s.Products.Delete(ctx, missing, 0, product.DeleteArchive)
+	wantErr(t, "Delete", err, product.ErrNotFound)
+	_, err = s.Products.GetPrices(ctx, missing)
+	wantErr(t, "GetPrices", err, product.ErrNotFound)
+
+	//only deleted products can be restored
+	_, err = s.Products.Restore(ctx, p.ID)
+	wantErr(t, "Restore", err, product.ErrNotFound)
+}
+
+func testCreate(t *testing.T, ctx context.Context, s storagetest.Stores) {
+	first := create(t, ctx, s, "chair", 10)
+	second := create(t, ctx, s, "table", 20)
+
+	if first.ID == 0 || second.ID <= first.ID {
+		t.Errorf("got IDs %d and %d, want increasing IDs", first.ID, second.ID)
+	}
+	if first.Version != 1 {
+		t.Errorf("got version %d, want 1", first.Version)
+	}
+	if first.TenantID != tenant.Default {
+		t.Errorf("got tenant %q, want %q", first.TenantID, tenant.Default)
+	}
+	if first.CreatedAt.IsZero() || first.UpdatedAt.IsZero() {
+		t.Errorf("timestamps not set: created %v, updated %v", first.CreatedAt, first.UpdatedAt)
+	}
+
+	got, err := s.Products.GetById(ctx, second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "table" || got.Price != 20 || got.Version != 1 {
+		t.Errorf("got %+v, want table at 20 in version 1", got)
+	}
+	if !got.CreatedAt.Equal(second.CreatedAt) {
+		t.Errorf("got created_at %v, want %v", got.CreatedAt, second.CreatedAt)
+	}
+
+	prices, err := s.Products.GetPrices(ctx, second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prices) != 1 || prices[0].Price != 20 || prices[0].AppliedAt == nil {
+		t.Errorf("got history %+v, want the applied price 20", prices)
+	}
+
+	products, total, err := s.Products.GetAll(ctx, product.ListOptions{Page: 2, PerPage: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(products) != 1 || products[0].ID != second.ID {
+		t.Errorf("got page %+v of %d products, want the second of 2", products, total)
+	}
+}
+
+func testUpdate(t *testing.T, ctx context.Context, s storagetest.Stores) {
+	p := create(t, ctx, s, "chair", 10)
+
+	updated, err := s.Products.Update(ctx, p.ID, p.Version, product.InputProduct{Name: "armchair", Price: 15})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Version != 2 || updated.Name != "armchair" || updated.Price != 15 {
+		t.Errorf("got %+v, want armchair at 15 in version 2", updated)
+	}
+	if updated.UpdatedAt.Before(p.UpdatedAt) {
+		t.Errorf("updated_at went back from %v to %v", p.UpdatedAt, updated.UpdatedAt)
+	}
+
+	_, err = s.Products.Update(ctx, p.ID, p.Version, product.InputProduct{Name: "stool", Price: 5})
+	wantErr(t, "Update with a stale version", err, product.ErrVersionConflict)
+
+	//the name alone leaves the price history as it is
+	name := "big armchair"
+	patched, err := s.Products.Patch(ctx, p.ID, 0, product.PatchProduct{Name: &name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched.Version != 3 || patched.Name != name || patched.Price != 15 {
+		t.Errorf("got %+v, want %s at 15 in version 3", patched, name)
+	}
+
+	prices, err := s.Products.GetPrices(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prices) != 2 || prices[0].Price != 10 || prices[1].Price != 15 {
+		t.Errorf("got history %+v, want 10 then 15", prices)
+	}
+}
+
+func testSoftDelete(t *testing.T, ctx context.Context, s storagetest.Stores) {
+	p := create(t, ctx, s, "chair", 10)
+
+	err := s.Products.Delete(ctx, p.ID, p.Version+1, product.DeleteArchive)
+	wantErr(t, "Delete with a stale version", err, product.ErrVersionConflict)
+
+	err = s.Products.Delete(ctx, p.ID, p.Version, product.DeleteArchive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Products.GetById(ctx, p.ID)
+	wantErr(t, "GetById of a deleted product", err, product.ErrNotFound)
+	err = s.Products.Delete(ctx, p.ID, 0, product.DeleteArchive)
+	wantErr(t, "Delete of a deleted product", err, product.ErrNotFound)
+
+	_, total, err := s.Products.GetAll(ctx, product.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 0 {
+		t.Errorf("listed %d products, want the deleted one left out", total)
+	}
+
+	products, _, err := s.Products.GetAll(ctx, product.ListOptions{IncludeDeleted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 1 || !products[0].DeletedAt.Valid || products[0].Version != 2 {
+		t.Fatalf("got %+v, want the product deleted in version 2", products)
+	}
+
+	restored, err := s.Products.Restore(ctx, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.DeletedAt.Valid || restored.Version != 3 {
+		t.Errorf("got %+v, want the product restored in version 3", restored)
+	}
+
+	_, err = s.Products.Restore(ctx, p.ID)
+	wantErr(t, "Restore of a restored product", err, product.ErrNotFound)
+	_, err = s.Products.GetById(ctx, p.ID)
+	if err != nil {
+		t.Errorf("GetById of a restored product: %v", err)
+	}
+}
+
+func testBlockPolicy(t *testing.T, ctx context.Context, s storagetest.Stores) {
+	p := create(t, ctx, s, "chair", 10)
+	paid, err := s.Payments.Create(ctx, payment.Payment{ProductID: p.ID, PricePaid: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Products.Delete(ctx, p.ID, 0, product.DeleteBlock)
+	wantErr(t, "Delete of a paid product", err, product.ErrProductInUse)
+
+	//deleted payments no longer hold the product
+	err = s.Payments.Delete(ctx, paid.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Products.Delete(ctx, p.ID, 0, product.DeleteBlock)
+	if err != nil {
+		t.Errorf("Delete once the payment is deleted: %v", err)
+	}
+}
+
+func testArchivePolicy(t *testing.T, ctx context.Context, s storagetest.Stores) {
+	p := create(t, ctx, s, "chair", 10)
+	paid, err := s.Payments.Create(ctx, payment.Payment{ProductID: p.ID, PricePaid: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Products.Delete(ctx, p.ID, 0, product.DeleteArchive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Payments.GetById(ctx, paid.ID)
+	if err != nil {
+		t.Fatalf("payment of an archived product: %v", err)
+	}
+	if got.Version != 1 || got.Product == nil || !got.Product.DeletedAt.Valid {
+		t.Errorf("got %+v, want the payment untouched with its deleted product", got)
+	}
+}
+
+func testCascadePolicy(t *testing.T, ctx context.Context, s storagetest.Stores) {
+	recorder := audit.NewService(s.Audit)
+	payments := payment.NewService(s.Payments, s.Tx, recorder)
+	products := product.NewService(s.Products, s.Tx, product.DeleteCascade, recorder, payments)
+
+	p := create(t, ctx, s, "chair", 10)
+	other := create(t, ctx, s, "table", 20)
+	var paid []payment.Payment
+	for _, productID := range []int{p.ID, p.ID, other.ID} {
+		created, err := s.Payments.Create(ctx, payment.Payment{ProductID: productID, PricePaid: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		paid = append(paid, created)
+	}
+
+	err := products.Delete(ctx, p.ID, p.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, created := range paid[:2] {
+		_, err = s.Payments.GetById(ctx, created.ID)
+		wantErr(t, "GetById of a cascaded payment", err, payment.ErrNotFound)
+	}
+	_, err = s.Payments.GetById(ctx, paid[2].ID)
+	if err != nil {
+		t.Errorf("payment of another product: %v", err)
+	}
+
+	deleted, _, err := s.Payments.GetAll(ctx, payment.ListOptions{IncludeDeleted: true, ProductID: p.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, got := range deleted {
+		if !got.DeletedAt.Valid || got.Version != 2 {
+			t.Errorf("got %+v, want the payment deleted in version 2", got)
+		}
+	}
+
+	entries, _, err := s.Audit.GetAll(ctx, audit.ListOptions{Entity: "payment", Action: audit.Delete})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("got %d payment deletions audited, want 2", len(entries))
+	}
+	entries, _, err = s.Audit.GetAll(ctx, audit.ListOptions{Entity: "product", Action: audit.Delete, EntityID: p.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d product deletions audited, want 1", len(entries))
+	}
+}
+
+func testTenants(t *testing.T, ctx context.Context, s storagetest.Stores) {
+	acme := tenant.WithID(ctx, "acme")
+	globex := tenant.WithID(ctx, "globex")
+	p := create(t, acme, s, "chair", 10)
+	create(t, globex, s, "table", 20)
+
+	_, err := s.Products.GetById(globex, p.ID)
+	wantErr(t, "GetById", err, product.ErrNotFound)
+	_, err = s.Products.GetPrices(globex, p.ID)
+	wantErr(t, "GetPrices", err, product.ErrNotFound)
+	products, total, err := s.Products.GetAll(globex, product.ListOptions{IncludeDeleted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || products[0].Name != "table" {
+		t.Errorf("got %+v, want the table of globex only", products)
+	}
+
+	_, err = s.Products.Update(globex, p.ID, 0, product.InputProduct{Name: "stool", Price: 5})
+	wantErr(t, "Update", err, product.ErrNotFound)
+	name := "stool"
+	_, err = s.Products.Patch(globex, p.ID, 0, product.PatchProduct{Name: &name})
+	wantErr(t, "Patch", err, product.ErrNotFound)
+	_, err = s.Products.SchedulePrice(globex, product.ProductPrice{ProductID: p.ID, Price: 5, EffectiveAt: time.Now().Add(time.Hour)})
+	wantErr(t, "SchedulePrice", err, product.ErrNotFound)
+	_, err = s.Products.ReplacePrices(globex, p.ID, nil)
+	wantErr(t, "ReplacePrices", err, product.ErrNotFound)
+	err = s.Products.Delete(globex, p.ID, 0, product.DeleteArchive)
+	wantErr(t, "Delete", err, product.ErrNotFound)
+
+	prices, err := s.Products.GetAllPrices(globex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, price := range prices {
+		if price.ProductID == p.ID {
+			t.Errorf("got the price %+v of acme", price)
+		}
+	}
+
+	err = s.Products.Delete(acme, p.ID, 0, product.DeleteArchive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Products.Restore(globex, p.ID)
+	wantErr(t, "Restore", err, product.ErrNotFound)
+
+	//nothing globex tried reached the product of acme
+	restored, err := s.Products.Restore(acme, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Name != "chair" || restored.Price != 10 || restored.Version != 3 {
+		t.Errorf("got %+v, want the chair at 10 in version 3", restored)
+	}
+	prices, err = s.Products.GetPrices(acme, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prices) != 1 {
+		t.Errorf("got history %+v, want the price of the creation only", prices)
+	}
+
+	//a context without tenant reaches nothing
+	_, err = s.Products.GetById(context.Background(), p.ID)
+	wantErr(t, "GetById without tenant", err, tenant.ErrMissing)
+	_, _, err = s.Products.GetAll(context.Background(), product.ListOptions{})
+	wantErr(t, "GetAll without tenant", err, tenant.ErrMissing)
+}
@@ -1,11 +1,30 @@
 package product
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Product struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	Price     float64   `json:"price"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        int            `json:"id"`
+	TenantID  string         `json:"-"`
+	Name      string         `json:"name"`
+	Price     float64        `json:"price"`
+	Version   int            `json:"version" gorm:"not null;default:1"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at"`
+}
+
+// ProductPrice is one entry of a product's price history. Entries with a nil
+// AppliedAt are scheduled changes still waiting for their EffectiveAt.
+type ProductPrice struct {
+	ID          int        `json:"id"`
+	ProductID   int        `json:"product_id"`
+	Product     *Product   `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Price       float64    `json:"price"`
+	EffectiveAt time.Time  `json:"effective_at"`
+	AppliedAt   *time.Time `json:"applied_at"`
+	CreatedAt   time.Time  `json:"created_at"`
 }
@@ -0,0 +1,11 @@
+package product
+
+import "go/src/apperror"
+
+var (
+	ErrNotFound        = apperror.NewNotFound("product_not_found", "product not found")
+	ErrInvalidPrice    = apperror.NewValidation("invalid_price", "price must not be negative")
+	ErrPastPrice       = apperror.NewValidation("past_effective_at", "effective_at must be in the future")
+	ErrProductInUse    = apperror.NewConflict("product_in_use", "product has payments")
+	ErrVersionConflict = apperror.NewPreconditionFailed("product_modified", "product was modified concurrently")
+)
@@ -1,6 +1,28 @@
 package product
 
+import "time"
+
 type InputProduct struct {
 	Name  string  `json:"name" binding:"required"`
 	Price float64 `json:"price" binding:"required"`
 }
+
+// PatchProduct is a JSON Merge Patch of a product, nil fields are left untouched.
+type PatchProduct struct {
+	Name  *string  `json:"name" binding:"omitempty,min=1"`
+	Price *float64 `json:"price" binding:"omitempty,gte=0"`
+}
+
+type InputPrice struct {
+	Price       float64   `json:"price" binding:"gte=0"`
+	EffectiveAt time.Time `json:"effective_at" binding:"required"`
+}
+
+// ListOptions selects the products to list. A zero PerPage lists all of them,
+// a non empty Query only the ones whose name matches it.
+type ListOptions struct {
+	IncludeDeleted bool
+	Query          string
+	Page           int
+	PerPage        int
+}
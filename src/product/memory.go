@@ -0,0 +1,434 @@
+package product
+
+import (
+	"context"
+	"go/src/tenant"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Dependents are the in-memory records referencing the products, so that the
+// block policy applies to them as it does to the payments table.
+type Dependents interface {
+	CountByProduct(productID int) int
+}
+
+// memoryRepository keeps the products in memory, for tests and demos. It
+// follows the semantics of the GORM repository: soft deletes, versions and
+// price history included.
+type memoryRepository struct {
+	mu         sync.RWMutex
+	products   map[int]Product
+	prices     []ProductPrice
+	lastID     int
+	lastPrice  int
+	dependents []Dependents
+}
+
+func NewMemoryRepository() *memoryRepository {
+	return &memoryRepository{products: make(map[int]Product)}
+}
+
+// Register makes the block policy look at dependents. It must be called
+// before the repository is used.
+func (r *memoryRepository) Register(dependents Dependents) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.dependents = append(r.dependents, dependents)
+}
+
+// View calls fn with the product of the tenant of ctx while keeping it from
+// being deleted or modified, like the share lock of the GORM repositories.
+func (r *memoryRepository) View(ctx context.Context, id int, fn func(product Product) error) error {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	product, err := r.get(tenantID, id)
+	if err != nil {
+		return err
+	}
+	return fn(product)
+}
+
+// Lookup returns the product, even deleted, whatever its tenant.
+func (r *memoryRepository) Lookup(id int) (Product, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	product, ok := r.products[id]
+	return product, ok
+}
+
+func (r *memoryRepository) Create(ctx context.Context, product Product) (Product, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return product, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.lastID++
+	product.ID = r.lastID
+	product.TenantID = tenantID
+	product.Version = 1
+	product.CreatedAt = now
+	product.UpdatedAt = now
+	product.DeletedAt = gorm.DeletedAt{}
+
+	r.products[product.ID] = product
+	r.recordPrice(product)
+
+	return product, nil
+}
+
+func (r *memoryRepository) GetAll(ctx context.Context, options ListOptions) ([]Product, int64, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	query := strings.ToLower(options.Query)
+	products := []Product{}
+	for _, product := range r.products {
+		if product.TenantID != tenantID {
+			continue
+		}
+		if product.DeletedAt.Valid && !options.IncludeDeleted {
+			continue
+		}
+		if !strings.Contains(strings.ToLower(product.Name), query) {
+			continue
+		}
+		products = append(products, product)
+	}
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].ID < products[j].ID
+	})
+
+	total := int64(len(products))
+	if options.PerPage > 0 {
+		start := (options.Page - 1) * options.PerPage
+		if start > len(products) {
+			start = len(products)
+		}
+		end := start + options.PerPage
+		if end > len(products) {
+			end = len(products)
+		}
+		products = products[start:end]
+	}
+
+	return products, total, nil
+}
+
+func (r *memoryRepository) GetById(ctx context.Context, id int) (Product, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return Product{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.get(tenantID, id)
+}
+
+func (r *memoryRepository) Update(ctx context.Context, id int, version int, inputProduct InputProduct) (Product, error) {
+	return r.update(ctx, id, version, func(product *Product) {
+		product.Name = inputProduct.Name
+		product.Price = inputProduct.Price
+	})
+}
+
+func (r *memoryRepository) Patch(ctx context.Context, id int, version int, patch PatchProduct) (Product, error) {
+	return r.update(ctx, id, version, func(product *Product) {
+		if patch.Name != nil {
+			product.Name = *patch.Name
+		}
+		if patch.Price != nil {
+			product.Price = *patch.Price
+		}
+	})
+}
+
+func (r *memoryRepository) update(ctx context.Context, id int, version int, apply func(product *Product)) (Product, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return Product{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	product, err := r.get(tenantID, id)
+	if err != nil {
+		return product, err
+	}
+
+	if version != 0 && product.Version != version {
+		return product, ErrVersionConflict
+	}
+
+	oldPrice := product.Price
+	apply(&product)
+	r.save(&product)
+
+	if product.Price != oldPrice {
+		r.recordPrice(product)
+	}
+
+	return product, nil
+}
+
+func (r *memoryRepository) Delete(ctx context.Context, id int, version int, policy DeletePolicy) error {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	product, err := r.get(tenantID, id)
+	if err != nil {
+		return err
+	}
+
+	if version != 0 && product.Version != version {
+		return ErrVersionConflict
+	}
+
+	if policy == DeleteBlock {
+		for _, dependents := range r.dependents {
+			if dependents.CountByProduct(id) > 0 {
+				return ErrProductInUse
+			}
+		}
+	}
+
+	product.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	r.save(&product)
+
+	return nil
+}
+
+func (r *memoryRepository) Restore(ctx context.Context, id int) (Product, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return Product{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	product, ok := r.products[id]
+	if !ok || product.TenantID != tenantID || !product.DeletedAt.Valid {
+		return Product{}, ErrNotFound
+	}
+
+	product.DeletedAt = gorm.DeletedAt{}
+	r.save(&product)
+
+	return product, nil
+}
+
+func (r *memoryRepository) GetPrices(ctx context.Context, id int) ([]ProductPrice, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	prices := []ProductPrice{}
+
+	_, err = r.get(tenantID, id)
+	if err != nil {
+		return prices, err
+	}
+
+	for _, price := range r.prices {
+		if price.ProductID == id {
+			prices = append(prices, price)
+		}
+	}
+	sort.SliceStable(prices, func(i, j int) bool {
+		return prices[i].EffectiveAt.Before(prices[j].EffectiveAt)
+	})
+
+	return prices, nil
+}
+
+func (r *memoryRepository) SchedulePrice(ctx context.Context, price ProductPrice) (ProductPrice, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return price, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err = r.get(tenantID, price.ProductID)
+	if err != nil {
+		return price, err
+	}
+
+	r.lastPrice++
+	price.ID = r.lastPrice
+	price.CreatedAt = time.Now()
+	r.prices = append(r.prices, price)
+
+	return price, nil
+}
+
+func (r *memoryRepository) GetAllPrices(ctx context.Context) ([]ProductPrice, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	prices := []ProductPrice{}
+	for _, price := range r.prices {
+		if r.products[price.ProductID].TenantID == tenantID {
+			prices = append(prices, price)
+		}
+	}
+	sort.SliceStable(prices, func(i, j int) bool {
+		if prices[i].ProductID != prices[j].ProductID {
+			return prices[i].ProductID < prices[j].ProductID
+		}
+		return prices[i].EffectiveAt.Before(prices[j].EffectiveAt)
+	})
+
+	return prices, nil
+}
+
+func (r *memoryRepository) ReplacePrices(ctx context.Context, id int, prices []ProductPrice) ([]ProductPrice, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	replaced := make([]ProductPrice, 0, len(prices))
+
+	_, err = r.get(tenantID, id)
+	if err != nil {
+		return replaced, err
+	}
+
+	kept := r.prices[:0]
+	for _, price := range r.prices {
+		if price.ProductID != id {
+			kept = append(kept, price)
+		}
+	}
+	r.prices = kept
+
+	for _, price := range prices {
+		r.lastPrice++
+		price.ID = r.lastPrice
+		price.ProductID = id
+		if price.CreatedAt.IsZero() {
+			price.CreatedAt = time.Now()
+		}
+		r.prices = append(r.prices, price)
+		replaced = append(replaced, price)
+	}
+
+	return replaced, nil
+}
+
+func (r *memoryRepository) GetDuePrices(ctx context.Context, now time.Time) ([]ProductPrice, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	due := []ProductPrice{}
+	for _, price := range r.prices {
+		if price.AppliedAt == nil && !price.EffectiveAt.After(now) {
+			due = append(due, price)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].EffectiveAt.Before(due[j].EffectiveAt)
+	})
+
+	return due, nil
+}
+
+func (r *memoryRepository) ApplyPrice(ctx context.Context, price ProductPrice, now time.Time) (Product, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.prices {
+		if r.prices[i].ID != price.ID || r.prices[i].AppliedAt != nil {
+			continue
+		}
+		applied := now
+		r.prices[i].AppliedAt = &applied
+
+		product, ok := r.products[price.ProductID]
+		if !ok || product.DeletedAt.Valid {
+			return Product{}, false, nil
+		}
+
+		product.Price = price.Price
+		r.save(&product)
+		return product, true, nil
+	}
+
+	return Product{}, false, nil
+}
+
+// get returns the product unless it is missing, deleted or of another
+// tenant, the caller holds the lock.
+func (r *memoryRepository) get(tenantID string, id int) (Product, error) {
+	product, ok := r.products[id]
+	if !ok || product.TenantID != tenantID || product.DeletedAt.Valid {
+		return Product{}, ErrNotFound
+	}
+	return product, nil
+}
+
+// save stores product as its next version, the caller holds the lock.
+func (r *memoryRepository) save(product *Product) {
+	product.Version++
+	product.UpdatedAt = time.Now()
+	r.products[product.ID] = *product
+}
+
+// recordPrice appends the current price of product to its history, the
+// caller holds the lock.
+func (r *memoryRepository) recordPrice(product Product) {
+	now := product.UpdatedAt
+	r.lastPrice++
+	r.prices = append(r.prices, ProductPrice{
+		ID:          r.lastPrice,
+		ProductID:   product.ID,
+		Price:       product.Price,
+		EffectiveAt: now,
+		AppliedAt:   &now,
+		CreatedAt:   now,
+	})
+}
@@ -0,0 +1,35 @@
+package product
+
+import (
+	"context"
+	"fmt"
+)
+
+// DeletePolicy decides what happens to the payments of a deleted product.
+type DeletePolicy string
+
+const (
+	// DeleteBlock refuses to delete a product that still has payments.
+	DeleteBlock DeletePolicy = "block"
+	// DeleteArchive soft deletes the product and keeps its payments.
+	DeleteArchive DeletePolicy = "archive"
+	// DeleteCascade soft deletes the product along with its payments.
+	DeleteCascade DeletePolicy = "cascade"
+)
+
+// Cascade deletes the records referencing a product deleted with the cascade
+// policy, in the transaction of ctx, and records their deletion.
+type Cascade interface {
+	DeleteByProduct(ctx context.Context, productID int) error
+}
+
+func ParseDeletePolicy(s string) (DeletePolicy, error) {
+	switch DeletePolicy(s) {
+	case "":
+		return DeleteArchive, nil
+	case DeleteBlock, DeleteArchive, DeleteCascade:
+		return DeletePolicy(s), nil
+	}
+
+	return "", fmt.Errorf("unknown product delete policy %q", s)
+}
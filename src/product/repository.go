@@ -1,19 +1,49 @@
 package product
 
 import (
+	"context"
 	"errors"
+	"go/src/database"
+	"go/src/tenant"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+// Repository only reaches the products of the tenant of ctx, except
+// GetDuePrices and ApplyPrice which apply the changes of every tenant. Delete
+// leaves the payments of a product deleted with DeleteCascade to the service.
 type Repository interface {
-	Create(product Product) (Product, error)
-	GetAll() ([]Product, error)
-	GetById(id int) (Product, error)
-	Update(id int, inputProduct InputProduct) (Product, error)
-	Delete(id int) error
+	Create(ctx context.Context, product Product) (Product, error)
+	GetAll(ctx context.Context, options ListOptions) ([]Product, int64, error)
+	GetById(ctx context.Context, id int) (Product, error)
+	Update(ctx context.Context, id int, version int, inputProduct InputProduct) (Product, error)
+	Patch(ctx context.Context, id int, version int, patch PatchProduct) (Product, error)
+	Delete(ctx context.Context, id int, version int, policy DeletePolicy) error
+	Restore(ctx context.Context, id int) (Product, error)
+	GetPrices(ctx context.Context, id int) ([]ProductPrice, error)
+	SchedulePrice(ctx context.Context, price ProductPrice) (ProductPrice, error)
+	GetAllPrices(ctx context.Context) ([]ProductPrice, error)
+	ReplacePrices(ctx context.Context, id int, prices []ProductPrice) ([]ProductPrice, error)
+	GetDuePrices(ctx context.Context, now time.Time) ([]ProductPrice, error)
+	ApplyPrice(ctx context.Context, price ProductPrice, now time.Time) (Product, bool, error)
 }
 
+// search keeps the products whose name matches query, using the full-text
+// index the migrations create for the dialect.
+func search(db *gorm.DB, query string) *gorm.DB {
+	switch database.Dialect(db) {
+	case database.MySQL:
+		return db.Where("MATCH (name) AGAINST (? IN NATURAL LANGUAGE MODE)", query)
+	case database.Postgres:
+		return db.Where("to_tsvector('simple', name) @@ plainto_tsquery('simple', ?)", query)
+	}
+	return db.Where("name LIKE ? ESCAPE '\\'", "%"+likeEscaper.Replace(query)+"%")
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 type repository struct {
 	db *gorm.DB
 }
@@ -22,45 +52,114 @@ func NewRepository(db *gorm.DB) *repository {
 	return &repository{db}
 }
 
-func (r *repository) Create(product Product) (Product, error) {
-	err := r.db.Create(&product).Error
+func (r *repository) Create(ctx context.Context, product Product) (Product, error) {
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return product, err
+	}
+	product.TenantID = tenantID
+	product.Version = 1
+
+	err = database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&product).Error
+		if err != nil {
+			return err
+		}
+
+		return recordPrice(tx, product)
+	})
 	if err != nil {
 		return product, err
 	}
 	return product, nil
 }
 
-func (r *repository) GetAll() ([]Product, error) {
+func (r *repository) GetAll(ctx context.Context, options ListOptions) ([]Product, int64, error) {
 	var products []Product
-	err := r.db.Find(&products).Error
+	var total int64
+
+	db := database.Conn(ctx, r.db).Scopes(tenant.Scope)
+	if options.IncludeDeleted {
+		db = db.Unscoped()
+	}
+	if options.Query != "" {
+		db = search(db, options.Query)
+	}
+	db = db.Session(&gorm.Session{})
+
+	err := db.Model(&Product{}).Count(&total).Error
 	if err != nil {
-		return products, err
+		return products, total, err
 	}
 
-	return products, nil
+	if options.PerPage > 0 {
+		db = db.Limit(options.PerPage).Offset((options.Page - 1) * options.PerPage)
+	}
+
+	err = db.Order("id").Find(&products).Error
+	if err != nil {
+		return products, total, err
+	}
+
+	return products, total, nil
 }
 
-func (r *repository) GetById(id int) (Product, error) {
+func (r *repository) GetById(ctx context.Context, id int) (Product, error) {
 	var product Product
 
-	err := r.db.Where(&Product{ID: id}).First(&product).Error
+	err := database.Conn(ctx, r.db).Scopes(tenant.Scope).Where(&Product{ID: id}).First(&product).Error
 	if err != nil {
-		return product, err
+		return product, notFound(err)
 	}
 
 	return product, nil
 }
 
-func (r *repository) Update(id int, inputProduct InputProduct) (Product, error) {
-	product, err := r.GetById(id)
+func (r *repository) Update(ctx context.Context, id int, version int, inputProduct InputProduct) (Product, error) {
+	return r.update(ctx, id, version, func(product *Product) {
+		product.Name = inputProduct.Name
+		product.Price = inputProduct.Price
+	})
+}
+
+func (r *repository) Patch(ctx context.Context, id int, version int, patch PatchProduct) (Product, error) {
+	return r.update(ctx, id, version, func(product *Product) {
+		if patch.Name != nil {
+			product.Name = *patch.Name
+		}
+		if patch.Price != nil {
+			product.Price = *patch.Price
+		}
+	})
+}
+
+// update applies the changes made by apply to the product and records its
+// new price when it changed. A version of 0 skips the If-Match check, the
+// write still fails if the product changed since it was read.
+func (r *repository) update(ctx context.Context, id int, version int, apply func(product *Product)) (Product, error) {
+	product, err := r.GetById(ctx, id)
 	if err != nil {
 		return product, err
 	}
 
-	product.Name = inputProduct.Name
-	product.Price = inputProduct.Price
+	if version != 0 && product.Version != version {
+		return product, ErrVersionConflict
+	}
+
+	oldPrice := product.Price
+	apply(&product)
+
+	err = database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		err := save(tx, &product)
+		if err != nil {
+			return err
+		}
 
-	err = r.db.Save(&product).Error
+		if product.Price == oldPrice {
+			return nil
+		}
+		return recordPrice(tx, product)
+	})
 	if err != nil {
 		return product, err
 	}
@@ -68,16 +167,243 @@ func (r *repository) Update(id int, inputProduct InputProduct) (Product, error)
 	return product, nil
 }
 
-func (r *repository) Delete(id int) error {
-	product := &Product{ID: id}
-	tx := r.db.Delete(product)
-	if tx.Error != nil {
-		return tx.Error
+// Delete soft deletes the product as its next version, so that the ETags of
+// the deleted product no longer match.
+func (r *repository) Delete(ctx context.Context, id int, version int, policy DeletePolicy) error {
+	return database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if version != 0 {
+			var product Product
+			err := tx.Scopes(tenant.Scope, database.ForUpdate).Where(&Product{ID: id}).First(&product).Error
+			if err != nil {
+				return notFound(err)
+			}
+			if product.Version != version {
+				return ErrVersionConflict
+			}
+		}
+
+		if policy == DeleteBlock {
+			//payments are looked up by table, the payment package depends on this one
+			var count int64
+			err := tx.Table("payments").Scopes(tenant.Scope).
+				Where("product_id = ? AND deleted_at IS NULL", id).
+				Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrProductInUse
+			}
+		}
+
+		result := tx.Model(&Product{}).Scopes(tenant.Scope).Where("id = ?", id).Updates(map[string]interface{}{
+			"deleted_at": time.Now(),
+			"version":    gorm.Expr("version + 1"),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		return nil
+	})
+}
+
+func (r *repository) Restore(ctx context.Context, id int) (Product, error) {
+	var product Product
+
+	db := database.Conn(ctx, r.db).Scopes(tenant.Scope).Unscoped().Session(&gorm.Session{})
+	err := db.Where("id = ? AND deleted_at IS NOT NULL", id).First(&product).Error
+	if err != nil {
+		return product, notFound(err)
+	}
+
+	err = db.Model(&product).Updates(map[string]interface{}{
+		"deleted_at": nil,
+		"version":    gorm.Expr("version + 1"),
+	}).Error
+	if err != nil {
+		return product, err
 	}
 
-	if tx.RowsAffected == 0 {
-		return errors.New("product not found")
+	return r.GetById(ctx, id)
+}
+
+func (r *repository) GetPrices(ctx context.Context, id int) ([]ProductPrice, error) {
+	var prices []ProductPrice
+
+	_, err := r.GetById(ctx, id)
+	if err != nil {
+		return prices, err
+	}
+
+	//the history has no tenant of its own, it follows the product checked above
+	err = database.Conn(ctx, r.db).Where(&ProductPrice{ProductID: id}).Order("effective_at").Find(&prices).Error
+	if err != nil {
+		return prices, err
+	}
+
+	return prices, nil
+}
+
+func (r *repository) SchedulePrice(ctx context.Context, price ProductPrice) (ProductPrice, error) {
+	_, err := r.GetById(ctx, price.ProductID)
+	if err != nil {
+		return price, err
+	}
+
+	err = database.Conn(ctx, r.db).Create(&price).Error
+	if err != nil {
+		return price, err
+	}
+
+	return price, nil
+}
+
+// GetAllPrices returns the price history of every product of the tenant,
+// deleted ones included, product by product.
+func (r *repository) GetAllPrices(ctx context.Context) ([]ProductPrice, error) {
+	var prices []ProductPrice
+
+	tenantID, err := tenant.Require(ctx)
+	if err != nil {
+		return prices, err
+	}
+
+	db := database.Conn(ctx, r.db)
+	products := db.Model(&Product{}).Unscoped().Where("tenant_id = ?", tenantID).Select("id")
+	err = db.Where("product_id IN (?)", products).Order("product_id, effective_at, id").Find(&prices).Error
+	if err != nil {
+		return prices, err
+	}
+
+	return prices, nil
+}
+
+// ReplacePrices replaces the price history of the product with prices, which
+// get new IDs.
+func (r *repository) ReplacePrices(ctx context.Context, id int, prices []ProductPrice) ([]ProductPrice, error) {
+	replaced := make([]ProductPrice, 0, len(prices))
+
+	_, err := r.GetById(ctx, id)
+	if err != nil {
+		return replaced, err
+	}
+
+	err = database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("product_id = ?", id).Delete(&ProductPrice{}).Error
+		if err != nil {
+			return err
+		}
+
+		for _, price := range prices {
+			price.ID = 0
+			price.ProductID = id
+			err = tx.Create(&price).Error
+			if err != nil {
+				return err
+			}
+			replaced = append(replaced, price)
+		}
+		return nil
+	})
+	if err != nil {
+		return replaced, err
+	}
+
+	return replaced, nil
+}
+
+// GetDuePrices lists the scheduled changes of every tenant due at now, the
+// earliest first.
+func (r *repository) GetDuePrices(ctx context.Context, now time.Time) ([]ProductPrice, error) {
+	var due []ProductPrice
+
+	err := database.Conn(ctx, r.db).Where("applied_at IS NULL AND effective_at <= ?", now).Order("effective_at").Find(&due).Error
+	if err != nil {
+		return due, err
+	}
+
+	return due, nil
+}
+
+// ApplyPrice applies a due change to its product, whatever its tenant. It
+// reports false when the change was applied already, or when its product is
+// deleted and the change is dropped.
+func (r *repository) ApplyPrice(ctx context.Context, price ProductPrice, now time.Time) (Product, bool, error) {
+	var product Product
+	applied := false
+
+	err := database.Conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		//claim the change first so that it is applied only once
+		claim := tx.Model(&ProductPrice{}).
+			Where("id = ? AND applied_at IS NULL", price.ID).
+			Update("applied_at", now)
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			return nil
+		}
+
+		err := tx.Where(&Product{ID: price.ProductID}).First(&product).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		product.Price = price.Price
+		applied = true
+		return save(tx, &product)
+	})
+	if err != nil {
+		return product, false, err
+	}
+
+	return product, applied, nil
+}
+
+// notFound turns a missing row into ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// save writes every field of product, provided nobody bumped its version in
+// the meantime, and moves it to the next version.
+func save(tx *gorm.DB, product *Product) error {
+	version := product.Version
+	product.Version++
+
+	result := tx.Model(product).Where("version = ?", version).Select("*").Updates(product)
+	if result.Error != nil {
+		product.Version = version
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		product.Version = version
+		return ErrVersionConflict
 	}
 
 	return nil
 }
+
+// recordPrice appends the current price of product to its history.
+func recordPrice(tx *gorm.DB, product Product) error {
+	now := product.UpdatedAt
+	price := &ProductPrice{
+		ProductID:   product.ID,
+		Price:       product.Price,
+		EffectiveAt: now,
+		AppliedAt:   &now,
+	}
+	return tx.Create(price).Error
+}
@@ -0,0 +1,53 @@
+package product_test
+
+import (
+	"context"
+	"go/src/database/databasetest"
+	"go/src/product"
+	"go/src/tenant"
+	"testing"
+)
+
+// TestSearch checks the LIKE search of SQLite, which must match the
+// wildcards of the query literally.
+func TestSearch(t *testing.T) {
+	r := product.NewRepository(databasetest.Open(t))
+	ctx := tenant.WithID(context.Background(), tenant.Default)
+	for _, name := range []string{"Chair 50% off", "Chair 5 off", "arm_chair", "armchair", `back\slash`} {
+		_, err := r.Create(ctx, product.Product{Name: name, Price: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"chair", []string{"Chair 50% off", "Chair 5 off", "arm_chair", "armchair"}},
+		{"%", []string{"Chair 50% off"}},
+		{"_", []string{"arm_chair"}},
+		{`\`, []string{`back\slash`}},
+		{"table", nil},
+	}
+	for _, tt := range tests {
+		products, total, err := r.GetAll(ctx, product.ListOptions{Query: tt.query})
+		if err != nil {
+			t.Fatalf("search %q: %v", tt.query, err)
+		}
+		var got []string
+		for _, p := range products {
+			got = append(got, p.Name)
+		}
+		if int(total) != len(tt.want) || len(got) != len(tt.want) {
+			t.Errorf("search %q: got %q, want %q", tt.query, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("search %q: got %q, want %q", tt.query, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package product
+
+import (
+	"context"
+	"go/src/broadcaster"
+	"go/src/request"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+type scheduler struct {
+	service     Service
+	broadcaster broadcaster.Broadcaster
+	interval    time.Duration
+	ctx         context.Context
+	cancel      context.CancelFunc
+	stopped     chan struct{}
+}
+
+// Scheduler periodically applies the scheduled price changes that became
+// due and publishes the updated products to the broadcaster. Stop cancels
+// the current run and waits for it to return, the prices applied so far
+// stay applied.
+type Scheduler interface {
+	Stop()
+}
+
+// tick applies the due prices under an ID of its own, which the logs, the
+// audit entries and the events of the run share, like those of a request.
+func (s *scheduler) tick() {
+	ctx := request.WithInfo(s.ctx, request.Info{ID: request.NewID()})
+
+	products, err := s.service.ApplyDuePrices(ctx)
+	if err != nil {
+		slog.ErrorCtx(ctx, "price scheduler failed", "error", err.Error())
+	}
+	if len(products) > 0 {
+		slog.InfoCtx(ctx, "scheduled prices applied", "products", len(products))
+	}
+
+	for _, product := range products {
+		s.broadcaster.Submit(product.TenantID, broadcaster.NewEvent(ctx, product))
+	}
+}
+
+func (s *scheduler) run() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.tick()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func NewScheduler(service Service, broadcaster broadcaster.Broadcaster, interval time.Duration) Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &scheduler{
+		service:     service,
+		broadcaster: broadcaster,
+		interval:    interval,
+		ctx:         ctx,
+		cancel:      cancel,
+		stopped:     make(chan struct{}),
+	}
+
+	go s.run()
+
+	return s
+}
+
+func (s *scheduler) Stop() {
+	s.cancel()
+	<-s.stopped
+}
@@ -1,45 +1,94 @@
 package product
 
+import (
+	"context"
+	"errors"
+	"go/src/audit"
+	"go/src/database"
+	"go/src/tenant"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+const (
+	auditEntity = "product"
+
+	actionSchedulePrice audit.Action = "schedule_price"
+	actionApplyPrice    audit.Action = "apply_price"
+)
+
+// changeRetries is how many times a change asked without a version is
+// retried when another request changes the product between the audit
+// snapshot and the change.
+const changeRetries = 3
+
 type Service interface {
-	Create(input InputProduct) (Product, error)
-	GetAll() ([]Product, error)
-	GetById(id int) (Product, error)
-	Update(id int, input InputProduct) (Product, error)
-	Delete(id int) error
+	Create(ctx context.Context, input InputProduct) (Product, error)
+	GetAll(ctx context.Context, options ListOptions) ([]Product, int64, error)
+	GetById(ctx context.Context, id int) (Product, error)
+	Update(ctx context.Context, id int, version int, input InputProduct) (Product, error)
+	Patch(ctx context.Context, id int, version int, patch PatchProduct) (Product, error)
+	Delete(ctx context.Context, id int, version int) error
+	Restore(ctx context.Context, id int) (Product, error)
+	GetPrices(ctx context.Context, id int) ([]ProductPrice, error)
+	SchedulePrice(ctx context.Context, id int, input InputPrice) (ProductPrice, error)
+	GetAllPrices(ctx context.Context) ([]ProductPrice, error)
+	ImportPrices(ctx context.Context, id int, prices []ProductPrice) ([]ProductPrice, error)
+	ApplyDuePrices(ctx context.Context) ([]Product, error)
 }
 
 type service struct {
-	repository Repository
+	repository   Repository
+	tx           database.Transactor
+	deletePolicy DeletePolicy
+	audit        audit.Recorder
+	cascade      Cascade
 }
 
-func NewService(r Repository) *service {
-	return &service{r}
+// NewService records each change in the audit log within its transaction.
+// cascade deletes the payments of the products deleted with DeleteCascade.
+func NewService(r Repository, tx database.Transactor, deletePolicy DeletePolicy, recorder audit.Recorder, cascade Cascade) *service {
+	return &service{r, tx, deletePolicy, recorder, cascade}
 }
 
-func (s *service) Create(input InputProduct) (Product, error) {
+func (s *service) Create(ctx context.Context, input InputProduct) (Product, error) {
 	var product Product
+	if input.Price < 0 {
+		return product, ErrInvalidPrice
+	}
+
 	product.Name = input.Name
 	product.Price = input.Price
 
-	product, err := s.repository.Create(product)
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		product, err = s.repository.Create(ctx, product)
+		if err != nil {
+			return err
+		}
+
+		return s.audit.Record(ctx, audit.Create, auditEntity, product.ID, nil, product)
+	})
 	if err != nil {
 		return product, err
 	}
 
+	slog.InfoCtx(ctx, "product created", "product_id", product.ID)
 	return product, nil
 }
 
-func (s *service) GetAll() ([]Product, error) {
-	products, err := s.repository.GetAll()
+func (s *service) GetAll(ctx context.Context, options ListOptions) ([]Product, int64, error) {
+	products, total, err := s.repository.GetAll(ctx, options)
 	if err != nil {
-		return products, err
+		return products, total, err
 	}
 
-	return products, nil
+	return products, total, nil
 }
 
-func (s *service) GetById(id int) (Product, error) {
-	product, err := s.repository.GetById(id)
+func (s *service) GetById(ctx context.Context, id int) (Product, error) {
+	product, err := s.repository.GetById(ctx, id)
 	if err != nil {
 		return product, err
 	}
@@ -47,20 +96,223 @@ func (s *service) GetById(id int) (Product, error) {
 	return product, nil
 }
 
-func (s *service) Update(id int, input InputProduct) (Product, error) {
-	product, err := s.repository.Update(id, input)
+func (s *service) Update(ctx context.Context, id int, version int, input InputProduct) (Product, error) {
+	if input.Price < 0 {
+		return Product{}, ErrInvalidPrice
+	}
+
+	product, err := s.change(ctx, id, version, audit.Update, func(ctx context.Context, version int) (Product, error) {
+		return s.repository.Update(ctx, id, version, input)
+	})
 	if err != nil {
 		return product, err
 	}
 
+	slog.InfoCtx(ctx, "product updated", "product_id", id)
 	return product, nil
 }
 
-func (s *service) Delete(id int) error {
-	err := s.repository.Delete(id)
+func (s *service) Patch(ctx context.Context, id int, version int, patch PatchProduct) (Product, error) {
+	if patch.Price != nil && *patch.Price < 0 {
+		return Product{}, ErrInvalidPrice
+	}
+
+	product, err := s.change(ctx, id, version, audit.Update, func(ctx context.Context, version int) (Product, error) {
+		return s.repository.Patch(ctx, id, version, patch)
+	})
+	if err != nil {
+		return product, err
+	}
+
+	slog.InfoCtx(ctx, "product updated", "product_id", id)
+	return product, nil
+}
+
+// Delete deletes the product and, with the cascade policy, its payments in
+// the same transaction.
+func (s *service) Delete(ctx context.Context, id int, version int) error {
+	_, err := s.change(ctx, id, version, audit.Delete, func(ctx context.Context, version int) (Product, error) {
+		err := s.repository.Delete(ctx, id, version, s.deletePolicy)
+		if err != nil || s.deletePolicy != DeleteCascade {
+			return Product{}, err
+		}
+
+		return Product{}, s.cascade.DeleteByProduct(ctx, id)
+	})
 	if err != nil {
 		return err
 	}
 
+	slog.InfoCtx(ctx, "product deleted", "product_id", id)
 	return nil
 }
+
+func (s *service) Restore(ctx context.Context, id int) (Product, error) {
+	var product Product
+
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		product, err = s.repository.Restore(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		return s.audit.Record(ctx, audit.Restore, auditEntity, id, nil, product)
+	})
+	if err != nil {
+		return product, err
+	}
+
+	slog.InfoCtx(ctx, "product restored", "product_id", id)
+	return product, nil
+}
+
+func (s *service) GetPrices(ctx context.Context, id int) ([]ProductPrice, error) {
+	prices, err := s.repository.GetPrices(ctx, id)
+	if err != nil {
+		return prices, err
+	}
+
+	return prices, nil
+}
+
+func (s *service) SchedulePrice(ctx context.Context, id int, input InputPrice) (ProductPrice, error) {
+	var price ProductPrice
+	if input.Price < 0 {
+		return price, ErrInvalidPrice
+	}
+	if !input.EffectiveAt.After(time.Now()) {
+		return price, ErrPastPrice
+	}
+
+	price.ProductID = id
+	price.Price = input.Price
+	price.EffectiveAt = input.EffectiveAt
+
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		price, err = s.repository.SchedulePrice(ctx, price)
+		if err != nil {
+			return err
+		}
+
+		return s.audit.Record(ctx, actionSchedulePrice, auditEntity, id, nil, price)
+	})
+	if err != nil {
+		return price, err
+	}
+
+	slog.InfoCtx(ctx, "product price scheduled", "product_id", id, "effective_at", price.EffectiveAt)
+	return price, nil
+}
+
+func (s *service) GetAllPrices(ctx context.Context) ([]ProductPrice, error) {
+	prices, err := s.repository.GetAllPrices(ctx)
+	if err != nil {
+		return prices, err
+	}
+
+	return prices, nil
+}
+
+// ImportPrices replaces the price history of the product with the one of an
+// export. Its pending changes are recorded as scheduled.
+func (s *service) ImportPrices(ctx context.Context, id int, prices []ProductPrice) ([]ProductPrice, error) {
+	err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		prices, err = s.repository.ReplacePrices(ctx, id, prices)
+		if err != nil {
+			return err
+		}
+
+		for _, price := range prices {
+			if price.AppliedAt != nil {
+				continue
+			}
+			err = s.audit.Record(ctx, actionSchedulePrice, auditEntity, id, nil, price)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return prices, err
+	}
+
+	return prices, nil
+}
+
+// ApplyDuePrices applies each due change in a transaction of its own, on
+// error the products returned still had their price applied.
+func (s *service) ApplyDuePrices(ctx context.Context) ([]Product, error) {
+	var products []Product
+
+	now := time.Now()
+	due, err := s.repository.GetDuePrices(ctx, now)
+	if err != nil {
+		return products, err
+	}
+
+	for _, price := range due {
+		var product Product
+		applied := false
+
+		err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+			var err error
+			product, applied, err = s.repository.ApplyPrice(ctx, price, now)
+			if err != nil || !applied {
+				return err
+			}
+
+			ctx = tenant.WithID(ctx, product.TenantID)
+			return s.audit.Record(ctx, actionApplyPrice, auditEntity, product.ID, nil, product)
+		})
+		if err != nil {
+			return products, err
+		}
+
+		if applied {
+			slog.InfoCtx(tenant.WithID(ctx, product.TenantID), "product price applied", "product_id", product.ID, "price", product.Price)
+			products = append(products, product)
+		}
+	}
+
+	return products, nil
+}
+
+// change reads the product for the audit, then applies the change at the
+// version read, so that the product changed is the one read, and records it
+// in the same transaction. A deletion is recorded without the product after.
+func (s *service) change(ctx context.Context, id int, version int, action audit.Action, apply func(ctx context.Context, version int) (Product, error)) (Product, error) {
+	for attempt := 0; ; attempt++ {
+		var after Product
+
+		err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+			before, err := s.repository.GetById(ctx, id)
+			if err != nil {
+				return err
+			}
+
+			expected := version
+			if expected == 0 {
+				expected = before.Version
+			}
+
+			after, err = apply(ctx, expected)
+			if err != nil {
+				return err
+			}
+
+			if action == audit.Delete {
+				return s.audit.Record(ctx, action, auditEntity, id, before, nil)
+			}
+			return s.audit.Record(ctx, action, auditEntity, id, before, after)
+		})
+		if errors.Is(err, ErrVersionConflict) && version == 0 && attempt < changeRetries {
+			slog.DebugCtx(ctx, "product changed concurrently, retrying", "product_id", id, "attempt", attempt+1)
+			continue
+		}
+		return after, err
+	}
+}
@@ -0,0 +1,108 @@
+package product
+
+import (
+	"context"
+	"go/src/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// tracedService traces each call to the Service it wraps in a span of its
+// own, under which the queries of the call are traced.
+type tracedService struct {
+	service Service
+}
+
+func NewTracedService(s Service) *tracedService {
+	return &tracedService{s}
+}
+
+func productID(id int) attribute.KeyValue {
+	return attribute.Int("product.id", id)
+}
+
+func (t *tracedService) Create(ctx context.Context, input InputProduct) (Product, error) {
+	ctx, span := tracing.Start(ctx, "product.Service/Create")
+	product, err := t.service.Create(ctx, input)
+	span.SetAttributes(productID(product.ID))
+	tracing.End(span, err)
+	return product, err
+}
+
+func (t *tracedService) GetAll(ctx context.Context, options ListOptions) ([]Product, int64, error) {
+	ctx, span := tracing.Start(ctx, "product.Service/GetAll")
+	products, total, err := t.service.GetAll(ctx, options)
+	tracing.End(span, err)
+	return products, total, err
+}
+
+func (t *tracedService) GetById(ctx context.Context, id int) (Product, error) {
+	ctx, span := tracing.Start(ctx, "product.Service/GetById", productID(id))
+	product, err := t.service.GetById(ctx, id)
+	tracing.End(span, err)
+	return product, err
+}
+
+func (t *tracedService) Update(ctx context.Context, id int, version int, input InputProduct) (Product, error) {
+	ctx, span := tracing.Start(ctx, "product.Service/Update", productID(id))
+	product, err := t.service.Update(ctx, id, version, input)
+	tracing.End(span, err)
+	return product, err
+}
+
+func (t *tracedService) Patch(ctx context.Context, id int, version int, patch PatchProduct) (Product, error) {
+	ctx, span := tracing.Start(ctx, "product.Service/Patch", productID(id))
+	product, err := t.service.Patch(ctx, id, version, patch)
+	tracing.End(span, err)
+	return product, err
+}
+
+func (t *tracedService) Delete(ctx context.Context, id int, version int) error {
+	ctx, span := tracing.Start(ctx, "product.Service/Delete", productID(id))
+	err := t.service.Delete(ctx, id, version)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedService) Restore(ctx context.Context, id int) (Product, error) {
+	ctx, span := tracing.Start(ctx, "product.Service/Restore", productID(id))
+	product, err := t.service.Restore(ctx, id)
+	tracing.End(span, err)
+	return product, err
+}
+
+func (t *tracedService) GetPrices(ctx context.Context, id int) ([]ProductPrice, error) {
+	ctx, span := tracing.Start(ctx, "product.Service/GetPrices", productID(id))
+	prices, err := t.service.GetPrices(ctx, id)
+	tracing.End(span, err)
+	return prices, err
+}
+
+func (t *tracedService) SchedulePrice(ctx context.Context, id int, input InputPrice) (ProductPrice, error) {
+	ctx, span := tracing.Start(ctx, "product.Service/SchedulePrice", productID(id))
+	price, err := t.service.SchedulePrice(ctx, id, input)
+	tracing.End(span, err)
+	return price, err
+}
+
+func (t *tracedService) GetAllPrices(ctx context.Context) ([]ProductPrice, error) {
+	ctx, span := tracing.Start(ctx, "product.Service/GetAllPrices")
+	prices, err := t.service.GetAllPrices(ctx)
+	tracing.End(span, err)
+	return prices, err
+}
+
+func (t *tracedService) ImportPrices(ctx context.Context, id int, prices []ProductPrice) ([]ProductPrice, error) {
+	ctx, span := tracing.Start(ctx, "product.Service/ImportPrices", productID(id))
+	prices, err := t.service.ImportPrices(ctx, id, prices)
+	tracing.End(span, err)
+	return prices, err
+}
+
+func (t *tracedService) ApplyDuePrices(ctx context.Context) ([]Product, error) {
+	ctx, span := tracing.Start(ctx, "product.Service/ApplyDuePrices")
+	products, err := t.service.ApplyDuePrices(ctx)
+	span.SetAttributes(attribute.Int("product.count", len(products)))
+	tracing.End(span, err)
+	return products, err
+}
@@ -0,0 +1,44 @@
+package ratelimit
+
+import "sync"
+
+type connections struct {
+	mu   sync.Mutex
+	max  int
+	open map[string]int
+}
+
+// Connections caps the connections each client holds open at once, a zero
+// max does not. Every successful Acquire must be followed by a Release.
+type Connections interface {
+	Acquire(client string) bool
+	Release(client string)
+}
+
+func NewConnections(max int) *connections {
+	return &connections{
+		max:  max,
+		open: make(map[string]int),
+	}
+}
+
+func (c *connections) Acquire(client string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.max > 0 && c.open[client] >= c.max {
+		return false
+	}
+	c.open[client]++
+	return true
+}
+
+func (c *connections) Release(client string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.open[client]--
+	if c.open[client] <= 0 {
+		delete(c.open, client)
+	}
+}
@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit allows each client Requests per Period, in bursts of up to Requests.
+// A zero Requests disables it.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Result is the state of the bucket of a client after a request.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	//RetryAfter is how long a refused client waits for its next request
+	RetryAfter time.Duration
+	//Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+type limiter struct {
+	mu      sync.Mutex
+	limit   Limit
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// Limiter keeps a token bucket per client: a client starts with
+// Limit.Requests tokens, each request takes one, and they come back at the
+// rate of Limit.Requests per Limit.Period.
+type Limiter interface {
+	Allow(client string) Result
+}
+
+func NewLimiter(limit Limit) *limiter {
+	return &limiter{
+		limit:   limit,
+		buckets: make(map[string]*bucket),
+		swept:   time.Now(),
+	}
+}
+
+func (l *limiter) Allow(client string) Result {
+	now := time.Now()
+	capacity := float64(l.limit.Requests)
+	rate := capacity / l.limit.Period.Seconds()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: capacity}
+		l.buckets[client] = b
+	} else {
+		b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	}
+	b.updated = now
+
+	result := Result{Limit: l.limit.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((capacity - b.tokens) / rate)
+
+	return result
+}
+
+// sweep forgets the buckets that are full again, at most once per period,
+// so that clients seen once do not pile up. The caller holds the lock.
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.limit.Period {
+		return
+	}
+	l.swept = now
+
+	for client, b := range l.buckets {
+		if now.Sub(b.updated) >= l.limit.Period {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
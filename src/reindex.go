@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/src/config"
+	"go/src/database"
+)
+
+// runReindex rebuilds the indexes of a migrated database.
+func runReindex(args []string) error {
+	cfg, err := config.Load(args)
+	if err != nil {
+		return err
+	}
+	err = setupLogging(cfg.Log)
+	if err != nil {
+		return err
+	}
+	if cfg.Database.Driver == "memory" {
+		return errors.New("the memory driver has no index to rebuild")
+	}
+
+	db, err := database.Open(cfg.Database)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	err = migrator.Check()
+	if err != nil {
+		return err
+	}
+
+	err = database.Reindex(db)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("indexes rebuilt")
+	return nil
+}
@@ -0,0 +1,35 @@
+package request
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Info identifies the HTTP request a context belongs to.
+type Info struct {
+	ID string
+	IP string
+}
+
+type key struct{}
+
+// WithInfo returns a copy of ctx belonging to the request described by info.
+func WithInfo(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, key{}, info)
+}
+
+// FromContext returns the request ctx belongs to, if any.
+func FromContext(ctx context.Context) (Info, bool) {
+	info, ok := ctx.Value(key{}).(Info)
+	return info, ok
+}
+
+// NewID returns a random request ID, for the requests that come without one
+// and the work started by the API itself.
+func NewID() string {
+	id := make([]byte, 16)
+	//crypto/rand does not fail on the supported platforms
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/src/audit"
+	"go/src/config"
+	"go/src/payment"
+	"go/src/product"
+	"go/src/tenant"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// seedItem is a kind of product with its price range. Seeded product names
+// are an adjective, a material and an item.
+type seedItem struct {
+	name     string
+	min, max float64
+}
+
+var (
+	seedAdjectives = []string{"Ergonomic", "Rustic", "Sleek", "Compact", "Vintage", "Handmade", "Refined", "Practical", "Elegant", "Durable"}
+	seedMaterials  = []string{"Wooden", "Steel", "Cotton", "Leather", "Bamboo", "Granite", "Wool", "Ceramic", "Glass", "Linen"}
+	seedItems      = []seedItem{
+		{"Chair", 25, 350},
+		{"Table", 80, 900},
+		{"Lamp", 15, 180},
+		{"Mug", 4, 25},
+		{"Backpack", 30, 220},
+		{"Keyboard", 20, 250},
+		{"Shirt", 10, 90},
+		{"Wallet", 12, 120},
+		{"Bottle", 5, 45},
+		{"Clock", 18, 300},
+	}
+)
+
+// runSeed fills the database with a fake catalog and a history of payments,
+// going through the services like the API does.
+func runSeed(args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	productCount := flags.Int("products", 50, "number of products to create")
+	paymentCount := flags.Int("payments", 500, "number of payments to create")
+	priceChanges := flags.Float64("price-changes", 0.05, "probability for a product to change price before each payment")
+	seed := flags.Int64("seed", 0, "seed of the generator, 0 for a random one")
+	tenantID := flags.String("tenant", tenant.Default, "tenant to seed")
+
+	cfg, err := config.LoadFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	err = setupLogging(cfg.Log)
+	if err != nil {
+		return err
+	}
+	if *productCount < 1 && *paymentCount > 0 {
+		return errors.New("payments need at least one product")
+	}
+	ctx, err := tenantContext(*tenantID)
+	if err != nil {
+		return err
+	}
+
+	storage, err := openStorage(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer storage.close()
+
+	deletePolicy, err := product.ParseDeletePolicy(cfg.Products.DeletePolicy)
+	if err != nil {
+		return err
+	}
+	auditService := audit.NewService(storage.audit)
+	paymentService := payment.NewService(storage.payments, storage.tx, auditService)
+	productService := product.NewService(storage.products, storage.tx, deletePolicy, auditService, paymentService)
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	random := rand.New(rand.NewSource(*seed))
+
+	products := make([]product.Product, 0, *productCount)
+	for i := 0; i < *productCount; i++ {
+		item := seedItems[random.Intn(len(seedItems))]
+		name := fmt.Sprintf("%s %s %s",
+			seedAdjectives[random.Intn(len(seedAdjectives))],
+			seedMaterials[random.Intn(len(seedMaterials))],
+			item.name)
+
+		created, err := productService.Create(ctx, product.InputProduct{
+			Name:  name,
+			Price: roundPrice(item.min + random.Float64()*(item.max-item.min)),
+		})
+		if err != nil {
+			return err
+		}
+		products = append(products, created)
+	}
+
+	for i := 0; i < *paymentCount; i++ {
+		index := random.Intn(len(products))
+
+		//prices move over time, so that the payments of a product differ
+		if random.Float64() < *priceChanges {
+			price := roundPrice(products[index].Price * (0.85 + random.Float64()*0.3))
+			updated, err := productService.Patch(ctx, products[index].ID, 0, product.PatchProduct{Price: &price})
+			if err != nil {
+				return err
+			}
+			products[index] = updated
+		}
+
+		//most payments are at the list price, some got a discount
+		pricePaid := products[index].Price
+		if random.Float64() < 0.2 {
+			pricePaid = roundPrice(pricePaid * (0.7 + random.Float64()*0.25))
+		}
+
+		_, err := paymentService.Create(ctx, payment.InputPayment{
+			ProductID: products[index].ID,
+			PricePaid: pricePaid,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("seeded %d products and %d payments (seed %d)\n", *productCount, *paymentCount, *seed)
+	return nil
+}
+
+func roundPrice(price float64) float64 {
+	return math.Round(price*100) / 100
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"go/src/audit"
+	"go/src/auth"
+	"go/src/config"
+	"go/src/database"
+	"go/src/handler"
+	"go/src/metrics"
+	"go/src/payment"
+	"go/src/product"
+	"go/src/tracing"
+)
+
+// storage holds the repositories of the configured driver, the transactions
+// they join, and the health checks of the database behind them.
+type storage struct {
+	tx       database.Transactor
+	products product.Repository
+	payments payment.Repository
+	users    auth.Repository
+	audit    audit.Repository
+	checks   []handler.HealthCheck
+	close    func() error
+}
+
+// openStorage connects to the database, refusing one with pending
+// migrations, or keeps everything in memory with the memory driver.
+func openStorage(cfg config.Database) (storage, error) {
+	if cfg.Driver == "memory" {
+		products := product.NewMemoryRepository()
+		return storage{
+			tx:       database.NewMemoryTransactor(),
+			products: products,
+			payments: payment.NewMemoryRepository(products),
+			users:    auth.NewMemoryRepository(),
+			audit:    audit.NewMemoryRepository(),
+			close:    func() error { return nil },
+		}, nil
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return storage{}, err
+	}
+	err = db.Use(metrics.GORM{})
+	if err != nil {
+		return storage{}, err
+	}
+	err = db.Use(tracing.GORM{})
+	if err != nil {
+		return storage{}, err
+	}
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return storage{}, err
+	}
+	err = migrator.Check()
+	if err != nil {
+		return storage{}, err
+	}
+	//from there on only, migrations may take longer than queries
+	err = db.Use(database.QueryTimeout(cfg.QueryTimeout))
+	if err != nil {
+		return storage{}, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return storage{}, err
+	}
+
+	return storage{
+		tx:       database.NewTransactor(db),
+		products: product.NewRepository(db),
+		payments: payment.NewRepository(db),
+		users:    auth.NewRepository(db),
+		audit:    audit.NewRepository(db),
+		checks: []handler.HealthCheck{
+			{Name: "database", Check: sqlDB.PingContext},
+			{Name: "migrations", Check: func(ctx context.Context) error {
+				migrator, err := database.NewMigrator(db.WithContext(ctx))
+				if err != nil {
+					return err
+				}
+				return migrator.Check()
+			}},
+		},
+		close: sqlDB.Close,
+	}, nil
+}
@@ -0,0 +1,66 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"go/src/apperror"
+	"regexp"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Default owns the data created before tenants existed, and the requests of
+// users bound to no tenant that do not pick one.
+const Default = "default"
+
+var (
+	// ErrMissing fails the queries run without a tenant, rather than letting
+	// them reach every tenant.
+	ErrMissing = errors.New("no tenant in context")
+
+	ErrInvalid = apperror.NewBadRequest("invalid_tenant", "tenant must be 1 to 64 lowercase letters, digits, - or _")
+)
+
+var pattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Valid reports whether id can name a tenant.
+func Valid(id string) bool {
+	return pattern.MatchString(id)
+}
+
+type key struct{}
+
+// WithID returns a copy of ctx scoped to the tenant id.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, key{}, id)
+}
+
+// FromContext returns the tenant ctx is scoped to, if any.
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(key{}).(string)
+	return id, ok
+}
+
+// Require returns the tenant ctx is scoped to, ErrMissing when there is none.
+func Require(ctx context.Context) (string, error) {
+	id, ok := FromContext(ctx)
+	if !ok {
+		return "", ErrMissing
+	}
+	return id, nil
+}
+
+// Scope keeps the rows of the tenant of the statement's context, set with
+// db.WithContext.
+func Scope(db *gorm.DB) *gorm.DB {
+	id, err := Require(db.Statement.Context)
+	if err != nil {
+		db.AddError(err)
+		return db
+	}
+	return db.Where(clause.Eq{
+		Column: clause.Column{Table: clause.CurrentTable, Name: "tenant_id"},
+		Value:  id,
+	})
+}
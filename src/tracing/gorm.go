@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "tracing:span"
+
+// GORM traces the queries run through a database, as children of the span
+// of the context of the query, registered with db.Use(tracing.GORM{}).
+type GORM struct{}
+
+func (GORM) Name() string {
+	return "tracing"
+}
+
+func (GORM) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	errs := []error{
+		callback.Create().Before("gorm:create").Register("tracing:before_create", start("create")),
+		callback.Create().After("gorm:create").Register("tracing:after_create", end),
+		callback.Query().Before("gorm:query").Register("tracing:before_query", start("query")),
+		callback.Query().After("gorm:query").Register("tracing:after_query", end),
+		callback.Update().Before("gorm:update").Register("tracing:before_update", start("update")),
+		callback.Update().After("gorm:update").Register("tracing:after_update", end),
+		callback.Delete().Before("gorm:delete").Register("tracing:before_delete", start("delete")),
+		callback.Delete().After("gorm:delete").Register("tracing:after_delete", end),
+		callback.Row().Before("gorm:row").Register("tracing:before_row", start("row")),
+		callback.Row().After("gorm:row").Register("tracing:after_row", end),
+		callback.Raw().Before("gorm:raw").Register("tracing:before_raw", start("raw")),
+		callback.Raw().After("gorm:raw").Register("tracing:after_raw", end),
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func start(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		name := "gorm." + operation
+		if db.Statement.Table != "" {
+			name += " " + db.Statement.Table
+		}
+
+		_, span := tracer.Start(db.Statement.Context, name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemKey.String(db.Dialector.Name()),
+				semconv.DBOperationKey.String(operation),
+				semconv.DBSQLTableKey.String(db.Statement.Table),
+			),
+		)
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+func end(db *gorm.DB) {
+	value, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	defer span.End()
+
+	//the statement only, its values may be personal data
+	span.SetAttributes(
+		semconv.DBStatementKey.String(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	//a missing record is an answer, the caller decides whether it is an error
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
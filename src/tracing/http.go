@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"go/src/request"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/semconv/v1.17.0/httpconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HTTP traces the requests served by the router, continuing the trace of
+// the client when it sends a traceparent header. Like for the metrics, spans
+// are named after the route pattern rather than the path.
+func HTTP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		name := c.Request.Method + " " + route
+		if route == "" {
+			name = c.Request.Method + " unmatched"
+		}
+
+		ctx, span := tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(httpconv.ServerRequest("", c.Request)...),
+		)
+		defer span.End()
+
+		if route != "" {
+			span.SetAttributes(semconv.HTTPRouteKey.String(route))
+		}
+		if info, ok := request.FromContext(ctx); ok {
+			span.SetAttributes(attribute.String("http.request_id", info.ID))
+		}
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+		span.SetStatus(httpconv.ServerStatus(status))
+		//the errors hidden behind a 500
+		for _, err := range c.Errors {
+			span.RecordError(err.Err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"go/src/auth"
+	"go/src/config"
+	"os"
+	"strings"
+)
+
+var errUserUsage = errors.New("usage: user add <username> [-roles cashier,editor] [-tenant acme] [flags], the password is read from the standard input")
+
+// runUser manages the accounts that log in to the API.
+func runUser(args []string) error {
+	if len(args) < 2 || args[0] != "add" {
+		return errUserUsage
+	}
+	username, args := args[1], args[2:]
+
+	flags := flag.NewFlagSet("user", flag.ContinueOnError)
+	roles := flags.String("roles", "", "comma separated roles of the user")
+	tenantID := flags.String("tenant", "", "tenant the user is bound to, none to let them pick one")
+
+	cfg, err := config.LoadFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	err = setupLogging(cfg.Log)
+	if err != nil {
+		return err
+	}
+
+	//the first line, so that the password can be piped or typed
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return errUserUsage
+	}
+	password = strings.TrimRight(password, "\r\n")
+
+	storage, err := openStorage(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer storage.close()
+
+	//no token is signed here, the secret does not matter
+	authService := auth.NewService(storage.users, nil, cfg.Auth.AccessTTL, cfg.Auth.RefreshTTL)
+
+	var roleNames []string
+	if *roles != "" {
+		roleNames = strings.Split(*roles, ",")
+	}
+
+	user, err := authService.CreateUser(context.Background(), auth.InputUser{
+		Username: username,
+		Password: password,
+		Roles:    roleNames,
+		TenantID: *tenantID,
+	})
+	if err != nil {
+		return err
+	}
+
+	bound := "any tenant"
+	if user.TenantID != "" {
+		bound = "tenant " + user.TenantID
+	}
+	fmt.Printf("user %s created with id %d, roles %s, on %s\n", user.Username, user.ID, strings.Join(user.Roles, ","), bound)
+	return nil
+}